@@ -12,7 +12,9 @@ import (
 	"github.com/rs/zerolog/log"
 	"go.etcd.io/bbolt"
 
+	"github.com/WinPooh32/peerstohttp/hls"
 	"github.com/WinPooh32/peerstohttp/settings"
+	"github.com/WinPooh32/peerstohttp/transcode"
 
 	"github.com/anacrolix/torrent/metainfo"
 )
@@ -37,6 +39,16 @@ type App struct {
 
 	db *bbolt.DB
 
+	warm    warmups
+	readers readers
+	hls     hlsMedia
+	thumbs  thumbs
+
+	service *settings.Settings
+
+	// Nil when transcoding is not configured.
+	transcoder *transcode.Transcoder
+
 	// Path to temporary data folder.
 	tmp string
 	cwd string
@@ -49,6 +61,18 @@ func New(service *settings.Settings) (*App, error) {
 
 	var client *torrent.Client
 	var store *bbolt.DB
+	var transcoder *transcode.Transcoder
+
+	if *service.TranscodeConfig != "" {
+		if *service.TranscodeIdle <= 0 {
+			return nil, fmt.Errorf("transcode idle timeout must be positive, got %d seconds", *service.TranscodeIdle)
+		}
+
+		transcoder, err = transcode.Load(*service.TranscodeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("load transcoding profiles: %w", err)
+		}
+	}
 
 	// Working directory.
 	if *service.DownloadDir == "" {
@@ -80,8 +104,26 @@ func New(service *settings.Settings) (*App, error) {
 		torrents: map[string]*torrent.Torrent{},
 		client:   client,
 		db:       store,
-		tmp:      tmp,
-		cwd:      cwd,
+		warm: warmups{
+			files:    map[*torrent.File]*warmup{},
+			streamed: map[*torrent.File]*warmup{},
+			pieces:   map[pieceKey]int{},
+		},
+		readers: readers{
+			files: map[*torrent.File]map[*sharedReader]struct{}{},
+		},
+		hls: hlsMedia{
+			files: map[*torrent.File]*hls.Media{},
+		},
+		service:    service,
+		transcoder: transcoder,
+		tmp:        tmp,
+		cwd:        cwd,
+	}
+
+	err = app.loadThumbs()
+	if err != nil {
+		return nil, fmt.Errorf("load thumbnails cache: %w", err)
 	}
 
 	go func() {
@@ -142,6 +184,14 @@ func (app *App) Client() *torrent.Client {
 	return app.client
 }
 
+func (app *App) Settings() *settings.Settings {
+	return app.service
+}
+
+func (app *App) Transcoder() *transcode.Transcoder {
+	return app.transcoder
+}
+
 func (app *App) Track(t *torrent.Torrent) (*torrent.Torrent, error) {
 	return app.TrackContext(context.Background(), t)
 }
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/binary"
+	"strconv"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	dbBucketThumbnails = "thumbnails"
+	// Keys of the thumbnails by sequence of caching, the oldest thumbnails are evicted first.
+	dbBucketThumbsOrder = "thumbnails-order"
+)
+
+// thumbs tracks the size of cached thumbnails.
+type thumbs struct {
+	size int64
+	mu   sync.Mutex
+}
+
+// Thumbnail returns the cached thumbnail of the torrent file.
+func (app *App) Thumbnail(hash metainfo.Hash, index int, size string) ([]byte, bool) {
+	var data = app.cacheGet(dbBucketThumbnails, cacheKey(hash, index, size))
+	return data, data != nil
+}
+
+// PutThumbnail caches the thumbnail of the torrent file.
+// The oldest thumbnails are evicted when the cache exceeds the thumbnails capacity.
+func (app *App) PutThumbnail(hash metainfo.Hash, index int, size string, data []byte) error {
+	var capacity = *app.service.ThumbsCapacity
+	if int64(len(data)) > capacity {
+		return nil
+	}
+
+	var key = cacheKey(hash, index, size)
+
+	app.thumbs.mu.Lock()
+	defer app.thumbs.mu.Unlock()
+
+	var total = app.thumbs.size
+
+	var err = app.db.Update(func(tx *bbolt.Tx) error {
+		var b = tx.Bucket([]byte(dbBucketThumbnails))
+		var order = tx.Bucket([]byte(dbBucketThumbsOrder))
+
+		if b.Get(key) != nil {
+			return nil
+		}
+
+		total += int64(len(data))
+
+		var evicted [][]byte
+
+		var c = order.Cursor()
+		for k, v := c.First(); k != nil && total > capacity; k, v = c.Next() {
+			total -= int64(len(b.Get(v)))
+			evicted = append(evicted, append([]byte(nil), k...))
+		}
+
+		for _, k := range evicted {
+			var err = b.Delete(order.Get(k))
+			if err != nil {
+				return err
+			}
+
+			err = order.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		var seq, err = order.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		err = order.Put(binary.BigEndian.AppendUint64(nil, seq), key)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	app.thumbs.size = total
+
+	return nil
+}
+
+// loadThumbs counts the size of cached thumbnails.
+// Thumbnails cached before the eviction order was kept are ordered by keys.
+func (app *App) loadThumbs() error {
+	var size int64
+
+	var err = app.db.Update(func(tx *bbolt.Tx) error {
+		var b = tx.Bucket([]byte(dbBucketThumbnails))
+		var order = tx.Bucket([]byte(dbBucketThumbsOrder))
+
+		var ordered, _ = order.Cursor().First()
+
+		return b.ForEach(func(k, v []byte) error {
+			size += int64(len(v))
+
+			if ordered != nil {
+				return nil
+			}
+
+			var seq, err = order.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			return order.Put(binary.BigEndian.AppendUint64(nil, seq), k)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	app.thumbs.mu.Lock()
+	app.thumbs.size = size
+	app.thumbs.mu.Unlock()
+
+	return nil
+}
+
+func cacheKey(hash metainfo.Hash, index int, suffix string) []byte {
+	var key = append(hash.Bytes(), '/')
+	key = strconv.AppendInt(key, int64(index), 10)
+	if suffix != "" {
+		key = append(key, '/')
+		key = append(key, suffix...)
+	}
+	return key
+}
+
+func (app *App) cacheGet(bucket string, key []byte) []byte {
+	var value []byte
+
+	_ = app.db.View(func(tx *bbolt.Tx) error {
+		var v = tx.Bucket([]byte(bucket)).Get(key)
+		if v != nil {
+			// Value is valid only during the transaction.
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+
+	return value
+}
+
+func (app *App) cachePut(bucket string, key, value []byte) error {
+	return app.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put(key, value)
+	})
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/WinPooh32/peerstohttp/archive"
+	"github.com/WinPooh32/peerstohttp/hls"
+)
+
+// Max count of parsed HLS media kept in memory.
+const maxHLSMedia = 32
+
+type hlsMedia struct {
+	files map[*torrent.File]*hls.Media
+	mu    sync.Mutex
+}
+
+// HLS returns the MP4 file packaged for HLS, sample tables are parsed once.
+func (app *App) HLS(ctx context.Context, file *torrent.File) (*hls.Media, error) {
+	app.hls.mu.Lock()
+	var m, ok = app.hls.files[file]
+	app.hls.mu.Unlock()
+
+	if ok {
+		return m, nil
+	}
+
+	var reader = file.NewReader()
+	defer reader.Close()
+
+	// Box headers are read by small ranges.
+	reader.SetReadahead(64 << 10)
+
+	m, err := hls.Parse(archive.NewReaderAt(ctx, reader), file.Length())
+	if err != nil {
+		return nil, err
+	}
+
+	app.hls.mu.Lock()
+	defer app.hls.mu.Unlock()
+
+	if len(app.hls.files) >= maxHLSMedia {
+		for f := range app.hls.files {
+			delete(app.hls.files, f)
+			break
+		}
+	}
+	app.hls.files[file] = m
+
+	return m, nil
+}
+
+// HLSSegment reads the media segment n of the file fetching its pieces at high priority.
+func (app *App) HLSSegment(ctx context.Context, file *torrent.File, m *hls.Media, n int) ([]byte, error) {
+	var off, size, err = m.Range(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var pieces = filePieces(file, off, size)
+
+	app.prioritize(file.Torrent(), pieces)
+	defer app.unprioritize(file.Torrent(), pieces)
+
+	var reader = file.NewReader()
+	defer reader.Close()
+
+	reader.SetReadahead(size)
+
+	return m.Segment(n, archive.NewReaderAt(ctx, reader))
+}
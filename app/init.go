@@ -105,7 +105,15 @@ func p2p(service *settings.Settings, cwd string) (*torrent.Client, error) {
 	var capacity int64
 
 	if *service.CacheCapacity > 0 {
+		// Thumbnails are cached in the db within the cache capacity.
 		capacity = *service.CacheCapacity
+		if *service.ThumbsCapacity > 0 {
+			capacity -= *service.ThumbsCapacity
+		}
+		if capacity <= 0 {
+			return nil, fmt.Errorf("cache capacity %d MiB doesn't exceed thumbnails capacity %d MiB", *service.CacheCapacity>>20, *service.ThumbsCapacity>>20)
+		}
+
 		res, err = makeResourceProvider(cwd, capacity)
 		if err != nil {
 			return nil, fmt.Errorf("make resource provider: %w", err)
@@ -130,9 +138,11 @@ func db(path string) (*bbolt.DB, error) {
 
 	// Create buckets.
 	err = db.Update(func(tx *bbolt.Tx) error {
-		var _, err = tx.CreateBucketIfNotExists([]byte(dbBucketInfo))
-		if err != nil {
-			return fmt.Errorf("create bucket: %w", err)
+		for _, name := range []string{dbBucketInfo, dbBucketThumbnails, dbBucketThumbsOrder, dbBucketMedia} {
+			var _, err = tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return fmt.Errorf("create bucket %s: %w", name, err)
+			}
 		}
 		return nil
 	})
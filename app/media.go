@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/archive"
+	"github.com/WinPooh32/peerstohttp/media"
+)
+
+const (
+	dbBucketMedia = "media"
+
+	// Bump to invalidate cached media info when probing is changed.
+	mediaVersion = "media-v1"
+	tagsVersion  = "tags-v1"
+)
+
+// MediaInfo probes media info of the torrent file, results are cached.
+func (app *App) MediaInfo(ctx context.Context, file *torrent.File) (media.Info, error) {
+	return app.readMedia(ctx, file, mediaVersion, media.Probe)
+}
+
+// MediaTags reads embedded tags of the torrent file, cached media info is used when it's available.
+func (app *App) MediaTags(ctx context.Context, file *torrent.File) (media.Info, error) {
+	var t = file.Torrent()
+
+	if info, ok := app.cachedMedia(cacheKey(t.InfoHash(), fileIndex(t, file), mediaVersion)); ok {
+		return info, nil
+	}
+
+	return app.readMedia(ctx, file, tagsVersion, media.ReadTags)
+}
+
+func (app *App) readMedia(ctx context.Context, file *torrent.File, version string,
+	read func(r io.ReaderAt, size int64, ext string) (media.Info, error),
+) (media.Info, error) {
+	var t = file.Torrent()
+	var key = cacheKey(t.InfoHash(), fileIndex(t, file), version)
+
+	if info, ok := app.cachedMedia(key); ok {
+		return info, nil
+	}
+
+	var reader = file.NewReader()
+	defer reader.Close()
+
+	// Headers are read by small ranges.
+	reader.SetReadahead(64 << 10)
+
+	info, err := read(archive.NewReaderAt(ctx, reader), file.Length(), filepath.Ext(file.Path()))
+	if err != nil && !errors.Is(err, media.ErrMalformed) && !errors.Is(err, media.ErrUnsupported) {
+		return info, err
+	}
+
+	// Don't probe malformed files again.
+	data, _ := json.Marshal(info)
+
+	var putErr = app.cachePut(dbBucketMedia, key, data)
+	if putErr != nil {
+		log.Warn().Err(putErr).Msg("cache media info")
+	}
+
+	return info, err
+}
+
+func (app *App) cachedMedia(key []byte) (media.Info, bool) {
+	var info media.Info
+
+	var data = app.cacheGet(dbBucketMedia, key)
+	if data == nil {
+		return info, false
+	}
+
+	return info, json.Unmarshal(data, &info) == nil
+}
+
+// Picture reads the embedded cover art of the torrent file.
+func (app *App) Picture(ctx context.Context, file *torrent.File) (media.Picture, error) {
+	var reader = file.NewReader()
+	defer reader.Close()
+
+	reader.SetReadahead(64 << 10)
+
+	return media.ReadPicture(archive.NewReaderAt(ctx, reader), file.Length(), filepath.Ext(file.Path()))
+}
+
+func fileIndex(t *torrent.Torrent, file *torrent.File) int {
+	for i, f := range t.Files() {
+		if f == file {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+type readers struct {
+	files map[*torrent.File]map[*sharedReader]struct{}
+	mu    sync.Mutex
+}
+
+// sharedReader is a streaming reader of the file coordinated with other readers of the same file.
+type sharedReader struct {
+	torrent.Reader
+
+	app  *App
+	file *torrent.File
+
+	// Guarded by readers mutex, negative readahead follows contiguous reads.
+	readahead int64
+	pos       int64
+	// End of the wanted readahead window.
+	end int64
+
+	close sync.Once
+}
+
+// NewReader opens a streaming reader of the file.
+// Readahead of the reader is cut at the position of the closest reader ahead of it
+// when the window of that reader covers the rest, so overlapping windows of concurrent
+// streams merge instead of competing for piece priorities.
+func (app *App) NewReader(file *torrent.File) torrent.Reader {
+	var r = &sharedReader{
+		Reader:    file.NewReader(),
+		app:       app,
+		file:      file,
+		readahead: -1,
+	}
+
+	app.readers.mu.Lock()
+	if app.readers.files[file] == nil {
+		app.readers.files[file] = map[*sharedReader]struct{}{}
+	}
+	app.readers.files[file][r] = struct{}{}
+	app.readers.mu.Unlock()
+
+	r.Reader.SetReadaheadFunc(r.readaheadFunc)
+
+	return r
+}
+
+// Readers returns count of open streaming readers of the file.
+func (app *App) Readers(file *torrent.File) int {
+	app.readers.mu.Lock()
+	defer app.readers.mu.Unlock()
+
+	return len(app.readers.files[file])
+}
+
+func (r *sharedReader) SetReadahead(readahead int64) {
+	r.app.readers.mu.Lock()
+	r.readahead = readahead
+	r.app.readers.mu.Unlock()
+
+	// Recalculate priorities.
+	r.Reader.SetReadaheadFunc(r.readaheadFunc)
+}
+
+func (r *sharedReader) Close() error {
+	r.close.Do(func() {
+		r.app.readers.mu.Lock()
+
+		delete(r.app.readers.files[r.file], r)
+
+		var last = len(r.app.readers.files[r.file]) == 0
+		if last {
+			delete(r.app.readers.files, r.file)
+		}
+
+		r.app.readers.mu.Unlock()
+
+		if last {
+			r.app.streamsClosed(r.file)
+		}
+	})
+
+	return r.Reader.Close()
+}
+
+// readaheadFunc is called while the torrent client is locked, it must not call the client.
+func (r *sharedReader) readaheadFunc(ctx torrent.ReadaheadContext) int64 {
+	r.app.readers.mu.Lock()
+	defer r.app.readers.mu.Unlock()
+
+	r.pos = ctx.CurrentPos
+
+	var readahead = r.readahead
+	if readahead < 0 {
+		readahead = ctx.CurrentPos - ctx.ContiguousReadStartPos
+	}
+
+	r.end = r.pos + readahead
+
+	var end = r.end
+
+	for other := range r.app.readers.files[r.file] {
+		if other == r || other.pos <= r.pos || other.pos >= end {
+			continue
+		}
+
+		// Data behind the leader is read already, its window prioritizes the rest.
+		if other.end >= r.end {
+			end = other.pos
+		}
+	}
+
+	return end - r.pos
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"github.com/anacrolix/torrent"
+)
+
+// Stats reports download progress of the torrent or its file.
+type Stats struct {
+	Size      int64   `json:"size"`
+	Completed int64   `json:"completed"`
+	Percent   float64 `json:"percent"`
+	Peers     int     `json:"peers"`
+	Seeders   int     `json:"seeders"`
+	// Total downloaded bytes of the torrent, clients compute download speed from it.
+	BytesRead int64 `json:"bytes_read"`
+	// Concurrent streams of the file or the torrent.
+	Readers int `json:"readers"`
+}
+
+// Stats returns stats of the file, or of the whole torrent when the file is nil.
+func (app *App) Stats(t *torrent.Torrent, file *torrent.File) Stats {
+	var ts = t.Stats()
+
+	var stats = Stats{
+		Peers:     ts.ActivePeers,
+		Seeders:   ts.ConnectedSeeders,
+		BytesRead: ts.BytesReadData.Int64(),
+	}
+
+	if file != nil {
+		stats.Size = file.Length()
+		stats.Completed = file.BytesCompleted()
+		stats.Readers = app.Readers(file)
+	} else {
+		stats.Size = t.Length()
+		stats.Completed = t.BytesCompleted()
+
+		for _, f := range t.Files() {
+			stats.Readers += app.Readers(f)
+		}
+	}
+
+	if stats.Size > 0 {
+		stats.Percent = float64(stats.Completed) * 100 / float64(stats.Size)
+	}
+
+	return stats
+}
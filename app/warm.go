@@ -0,0 +1,229 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/rs/zerolog/log"
+)
+
+// WarmState reports progress of a file warm-up.
+type WarmState struct {
+	Head     int64   `json:"head"`
+	Tail     int64   `json:"tail"`
+	Buffered float64 `json:"buffered"`
+	Ready    bool    `json:"ready"`
+}
+
+type pieceRange struct {
+	begin, end int
+}
+
+type warmup struct {
+	head, tail pieceRange
+	headSize   int64
+	tailSize   int64
+	timer      *time.Timer
+}
+
+type warmups struct {
+	files map[*torrent.File]*warmup
+	// Warm-ups followed by streams, kept until the last stream of the file is closed.
+	streamed map[*torrent.File]*warmup
+	// Count of warm-ups and segment reads prioritizing the piece.
+	pieces map[pieceKey]int
+	mu     sync.Mutex
+}
+
+type pieceKey struct {
+	t     *torrent.Torrent
+	index int
+}
+
+// Warm prioritizes the first head and the last tail bytes of the file.
+// Warm-up is cancelled when no stream of the file follows within the timeout.
+func (app *App) Warm(file *torrent.File, head, tail int64, timeout time.Duration) WarmState {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	if w, ok := app.warm.files[file]; ok {
+		w.timer.Stop()
+		app.warm.release(file.Torrent(), w.head, w.tail)
+	}
+
+	var w = &warmup{
+		head:     filePieces(file, 0, head),
+		tail:     filePieces(file, file.Length()-tail, tail),
+		headSize: head,
+		tailSize: tail,
+	}
+
+	app.warm.acquire(file.Torrent(), w.head, w.tail)
+
+	w.timer = time.AfterFunc(timeout, func() {
+		app.warm.mu.Lock()
+		defer app.warm.mu.Unlock()
+
+		if app.warm.files[file] != w {
+			return
+		}
+		delete(app.warm.files, file)
+
+		app.warm.release(file.Torrent(), w.head, w.tail)
+
+		log.Info().Msgf("warm-up of %s cancelled: no stream followed", file.DisplayPath())
+	})
+
+	app.warm.files[file] = w
+
+	return w.state(file.Torrent())
+}
+
+// WarmState returns progress of the file warm-up if it is in progress.
+func (app *App) WarmState(file *torrent.File) (WarmState, bool) {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	var w, ok = app.warm.files[file]
+	if !ok {
+		return WarmState{}, false
+	}
+
+	return w.state(file.Torrent()), true
+}
+
+// Streamed marks the file as opened for streaming, so its warm-up is not cancelled by the timeout.
+// Prioritized pieces are released when the last streaming reader of the file is closed.
+func (app *App) Streamed(file *torrent.File) {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	var w, ok = app.warm.files[file]
+	if !ok {
+		return
+	}
+
+	w.timer.Stop()
+	delete(app.warm.files, file)
+
+	if prev, ok := app.warm.streamed[file]; ok {
+		app.warm.release(file.Torrent(), prev.head, prev.tail)
+	}
+	app.warm.streamed[file] = w
+}
+
+// streamsClosed releases the warm-up followed by streams of the file.
+func (app *App) streamsClosed(file *torrent.File) {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	var w, ok = app.warm.streamed[file]
+	if !ok {
+		return
+	}
+
+	delete(app.warm.streamed, file)
+	app.warm.release(file.Torrent(), w.head, w.tail)
+}
+
+// prioritize raises priority of the pieces until unprioritize is called with the same pieces.
+func (app *App) prioritize(t *torrent.Torrent, ranges ...pieceRange) {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	app.warm.acquire(t, ranges...)
+}
+
+func (app *App) unprioritize(t *torrent.Torrent, ranges ...pieceRange) {
+	app.warm.mu.Lock()
+	defer app.warm.mu.Unlock()
+
+	app.warm.release(t, ranges...)
+}
+
+// acquire raises priority of the pieces not prioritized by others yet.
+func (ws *warmups) acquire(t *torrent.Torrent, ranges ...pieceRange) {
+	for _, pr := range ranges {
+		for i := pr.begin; i < pr.end; i++ {
+			var key = pieceKey{t: t, index: i}
+
+			ws.pieces[key]++
+			if ws.pieces[key] == 1 {
+				t.Piece(i).SetPriority(torrent.PiecePriorityHigh)
+			}
+		}
+	}
+}
+
+// release restores priority of the pieces not prioritized by others anymore.
+// Piece priorities are only set here, streams prioritize pieces by readahead,
+// so the piece priority before the first acquire is none.
+func (ws *warmups) release(t *torrent.Torrent, ranges ...pieceRange) {
+	for _, pr := range ranges {
+		for i := pr.begin; i < pr.end; i++ {
+			var key = pieceKey{t: t, index: i}
+
+			ws.pieces[key]--
+			if ws.pieces[key] > 0 {
+				continue
+			}
+
+			delete(ws.pieces, key)
+			t.Piece(i).SetPriority(torrent.PiecePriorityNone)
+		}
+	}
+}
+
+func (w *warmup) state(t *torrent.Torrent) WarmState {
+	var total, completed int64
+
+	for _, pr := range []pieceRange{w.head, w.tail} {
+		for i := pr.begin; i < pr.end; i++ {
+			var length = t.Piece(i).Info().Length()
+
+			total += length
+			if t.PieceState(i).Complete {
+				completed += length
+			} else {
+				completed += length - t.PieceBytesMissing(i)
+			}
+		}
+	}
+
+	var state = WarmState{
+		Head:     w.headSize,
+		Tail:     w.tailSize,
+		Buffered: 100,
+		Ready:    completed == total,
+	}
+
+	if total > 0 {
+		state.Buffered = float64(completed) * 100 / float64(total)
+	}
+
+	return state
+}
+
+// filePieces returns pieces covering size bytes of the file starting from off.
+func filePieces(file *torrent.File, off, size int64) pieceRange {
+	if off < 0 {
+		size += off
+		off = 0
+	}
+	if off+size > file.Length() {
+		size = file.Length() - off
+	}
+	if size <= 0 {
+		return pieceRange{}
+	}
+
+	var pieceLength = file.Torrent().Info().PieceLength
+	var begin = file.Offset() + off
+	var end = begin + size
+
+	return pieceRange{
+		begin: int(begin / pieceLength),
+		end:   int((end + pieceLength - 1) / pieceLength),
+	}
+}
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Separator divides the archive path and the entry path.
+const Separator = "!"
+
+var ErrEntryNotFound = errors.New("archive entry not found")
+
+// IsArchive reports whether the file name is a supported archive.
+func IsArchive(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// Split splits the path like "dir/file.zip/!/entry" into the archive and entry paths.
+func Split(path string) (archive, entry string, ok bool) {
+	var sep = "/" + Separator + "/"
+
+	var i = strings.Index(path, sep)
+	if i < 0 {
+		return "", "", false
+	}
+
+	archive, entry = path[:i], path[i+len(sep):]
+	if !IsArchive(archive) || entry == "" {
+		return "", "", false
+	}
+
+	return archive, entry, true
+}
+
+// Archive is a zip archive opened over the torrent file.
+type Archive struct {
+	*zip.Reader
+
+	at     *ReaderAt
+	reader torrent.Reader
+}
+
+// Open reads the central directory of the zip file.
+// Reader must be configured by the caller (responsiveness, readahead), it is closed with the archive.
+func Open(ctx context.Context, reader torrent.Reader, size int64) (*Archive, error) {
+	var at = NewReaderAt(ctx, reader)
+
+	var zr, err = zip.NewReader(at, size)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return &Archive{Reader: zr, at: at, reader: reader}, nil
+}
+
+// Entry returns the file entry by its path.
+func (a *Archive) Entry(name string) (*zip.File, error) {
+	for _, f := range a.File {
+		if f.Name == name && !f.FileInfo().IsDir() {
+			return f, nil
+		}
+	}
+	return nil, ErrEntryNotFound
+}
+
+// Section returns a seekable reader of the stored (not compressed) entry.
+func (a *Archive) Section(f *zip.File) (*io.SectionReader, error) {
+	if f.Method != zip.Store {
+		return nil, errors.New("archive entry is compressed")
+	}
+
+	var off, err = f.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NewSectionReader(a.at, off, int64(f.CompressedSize64)), nil
+}
+
+func (a *Archive) Close() error {
+	return a.reader.Close()
+}
+
+// ReaderAt provides random access to the torrent reader.
+type ReaderAt struct {
+	ctx    context.Context
+	reader torrent.Reader
+	mu     sync.Mutex
+}
+
+func NewReaderAt(ctx context.Context, reader torrent.Reader) *ReaderAt {
+	return &ReaderAt{ctx: ctx, reader: reader}
+}
+
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	var _, err = ra.reader.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for n < len(p) && err == nil {
+		var nn int
+		nn, err = ra.reader.ReadContext(ra.ctx, p[n:])
+		n += nn
+	}
+
+	if n == len(p) {
+		return n, nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,113 @@
+package charset
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charset is a text encoding.
+type Charset int
+
+const (
+	UTF8 Charset = iota
+	UTF16LE
+	UTF16BE
+	Windows1251
+	Windows1252
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Detect guesses encoding of the text.
+// Legacy single byte text is treated as cyrillic or western european.
+func Detect(b []byte) Charset {
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		return UTF8
+	case bytes.HasPrefix(b, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(b, bomUTF16BE):
+		return UTF16BE
+	case utf8.Valid(b):
+		return UTF8
+	}
+
+	// Cyrillic words consist of high bytes entirely, when western
+	// accented letters are mostly surrounded by ascii letters.
+	var runs, singles int
+
+	for i, c := range b {
+		if c < 0xC0 {
+			continue
+		}
+
+		var prev = i > 0 && b[i-1] >= 0xC0
+		var next = i+1 < len(b) && b[i+1] >= 0xC0
+
+		if prev || next {
+			runs++
+		} else {
+			singles++
+		}
+	}
+
+	if runs > singles {
+		return Windows1251
+	}
+
+	return Windows1252
+}
+
+// Decode converts the text to UTF-8 detecting its encoding.
+func Decode(b []byte) string {
+	return DecodeCharset(b, Detect(b))
+}
+
+// DecodeCharset converts the text in the encoding to UTF-8.
+func DecodeCharset(b []byte, cs Charset) string {
+	switch cs {
+	case UTF16LE, UTF16BE:
+		b = bytes.TrimPrefix(b, bomUTF16LE)
+		b = bytes.TrimPrefix(b, bomUTF16BE)
+
+		var u = make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if cs == UTF16LE {
+				u = append(u, uint16(b[i])|uint16(b[i+1])<<8)
+			} else {
+				u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+			}
+		}
+		return string(utf16.Decode(u))
+
+	case Windows1251:
+		return decodeTable(b, &windows1251)
+
+	case Windows1252:
+		return decodeTable(b, &windows1252)
+
+	default:
+		return strings.ToValidUTF8(string(bytes.TrimPrefix(b, bomUTF8)), "�")
+	}
+}
+
+func decodeTable(b []byte, table *[128]rune) string {
+	var sb strings.Builder
+	sb.Grow(len(b) * 2)
+
+	for _, c := range b {
+		if c < 0x80 {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteRune(table[c-0x80])
+		}
+	}
+
+	return sb.String()
+}
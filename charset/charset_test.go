@@ -0,0 +1,55 @@
+package charset
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want Charset
+	}{
+		{"ascii", "Hello, world", UTF8},
+		{"empty", "", UTF8},
+		{"utf-8", "Привет, мир", UTF8},
+		{"utf-8 bom", "\xef\xbb\xbf\xcf\xf0\xe8", UTF8},
+		{"utf-16le bom", "\xff\xfeH\x00i\x00", UTF16LE},
+		{"utf-16be bom", "\xfe\xff\x00H\x00i", UTF16BE},
+		{"windows-1251", "\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0", Windows1251},
+		{"windows-1252", "Caf\xe9 na\xefve r\xe9sum\xe9", Windows1252},
+		{"single high byte", "\xe9", Windows1252},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect([]byte(tt.text)); got != tt.want {
+				t.Errorf("Detect(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want string
+	}{
+		{"utf-8", "Привет", "Привет"},
+		{"utf-8 bom", "\xef\xbb\xbfHi", "Hi"},
+		{"utf-16le", "\xff\xfe\x1f\x04@\x048\x04", "При"},
+		{"utf-16be", "\xfe\xff\x04\x1f\x04@\x048", "При"},
+		{"utf-16 odd length", "\xff\xfeH\x00i", "H"},
+		{"utf-16 surrogate pair", "\xff\xfe\x3d\xd8\x00\xde", "😀"},
+		{"utf-16 unpaired surrogate", "\xff\xfe\x3d\xd8", "�"},
+		{"windows-1251", "\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0", "Привет, мир"},
+		{"windows-1252", "Caf\xe9 \x80", "Café €"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decode([]byte(tt.text)); got != tt.want {
+				t.Errorf("Decode(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/go-chi/chi"
@@ -14,6 +15,8 @@ import (
 
 	application "github.com/WinPooh32/peerstohttp/app"
 	peershttp "github.com/WinPooh32/peerstohttp/http"
+	"github.com/WinPooh32/peerstohttp/http/host"
+	list_render "github.com/WinPooh32/peerstohttp/http/render"
 	"github.com/WinPooh32/peerstohttp/settings"
 
 	"github.com/go-chi/chi/middleware"
@@ -47,6 +50,28 @@ func registerProfiler(r chi.Router) {
 	r.Handle("/debug/pprof/mutex", pprof.Handler("mutex"))
 }
 
+// recoverer is middleware.Recoverer letting http.ErrAbortHandler through,
+// so the server aborts responses of handlers giving up in the middle of a transfer.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			var rvr = recover()
+			if rvr == nil {
+				return
+			}
+
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+
+			middleware.PrintPrettyStack(rvr)
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func init() {
 	if !*settings.Service.JsonLogs {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
@@ -76,14 +101,39 @@ func main() {
 		}
 	}()
 
+	if dir := *settings.Service.Templates; dir != "" {
+		err = list_render.LoadTemplates(dir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("load templates")
+		}
+	}
+
+	err = host.SetBaseURL(*settings.Service.BaseURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("base url")
+	}
+
+	if proxies := *settings.Service.TrustedProxies; proxies != "" {
+		err = host.SetTrustedProxies(strings.Split(proxies, ","))
+		if err != nil {
+			log.Fatal().Err(err).Msg("trusted proxies")
+		}
+	}
+
 	// Init router
 	router = chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
+	router.Use(recoverer)
 	router.Use(newCors([]string{"*"}).Handler)
 
-	peershttp.RouteApp(router, app)
+	if prefix := host.Prefix(); prefix != "" {
+		router.Route(prefix, func(r chi.Router) {
+			peershttp.RouteApp(r, app)
+		})
+	} else {
+		peershttp.RouteApp(router, app)
+	}
 
 	// Enable service profiling
 	if *settings.Service.Profile {
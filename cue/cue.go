@@ -0,0 +1,180 @@
+package cue
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/WinPooh32/peerstohttp/charset"
+)
+
+// Frames per second of INDEX positions.
+const framesPerSecond = 75
+
+var ErrNoTracks = errors.New("cue sheet has no tracks")
+
+// Sheet is a parsed cue sheet.
+type Sheet struct {
+	Title     string
+	Performer string
+	Files     []File
+}
+
+// File is an audio file referenced by the sheet.
+type File struct {
+	Name   string
+	Tracks []Track
+}
+
+// Track is a track of the file.
+type Track struct {
+	Number    int
+	Title     string
+	Performer string
+	// Start and end offsets in seconds, zero end means the end of the file.
+	Start float64
+	End   float64
+}
+
+// IsCue reports whether the file extension is a cue sheet.
+func IsCue(ext string) bool {
+	return strings.EqualFold(ext, ".cue")
+}
+
+// Parse parses the cue sheet, text encoding is detected automatically.
+func Parse(src []byte) (*Sheet, error) {
+	var text = strings.TrimPrefix(charset.Decode(src), "\uFEFF")
+
+	var sheet = &Sheet{}
+	var file *File
+	var track *Track
+
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		var command, args = splitCommand(line)
+
+		switch command {
+		case "FILE":
+			sheet.Files = append(sheet.Files, File{Name: fileName(args)})
+			file = &sheet.Files[len(sheet.Files)-1]
+			track = nil
+
+		case "TRACK":
+			if file == nil {
+				continue
+			}
+			var fields = strings.Fields(args)
+			var number int
+			if len(fields) > 0 {
+				number, _ = strconv.Atoi(fields[0])
+			}
+			file.Tracks = append(file.Tracks, Track{Number: number, Start: -1})
+			track = &file.Tracks[len(file.Tracks)-1]
+
+		case "TITLE":
+			if track != nil {
+				track.Title = unquote(args)
+			} else {
+				sheet.Title = unquote(args)
+			}
+
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = unquote(args)
+			} else {
+				sheet.Performer = unquote(args)
+			}
+
+		case "INDEX":
+			var fields = strings.Fields(args)
+			if track == nil || len(fields) < 2 || fields[0] != "01" {
+				// Pregap INDEX 00 is a part of the previous track.
+				continue
+			}
+			var start, ok = parseTime(fields[1])
+			if ok {
+				track.Start = start
+			}
+		}
+	}
+
+	var tracks int
+
+	for i := range sheet.Files {
+		var f = &sheet.Files[i]
+		var valid = f.Tracks[:0]
+
+		for _, t := range f.Tracks {
+			if t.Start >= 0 {
+				valid = append(valid, t)
+			}
+		}
+
+		// Track ends where the next one starts.
+		for j := 0; j+1 < len(valid); j++ {
+			valid[j].End = valid[j+1].Start
+		}
+
+		f.Tracks = valid
+		tracks += len(valid)
+	}
+
+	if tracks == 0 {
+		return nil, ErrNoTracks
+	}
+
+	return sheet, nil
+}
+
+func splitCommand(line string) (string, string) {
+	line = strings.TrimSpace(line)
+
+	var command, args, _ = strings.Cut(line, " ")
+
+	return strings.ToUpper(command), strings.TrimSpace(args)
+}
+
+// fileName takes the quoted file name followed by the file type.
+func fileName(args string) string {
+	var name string
+
+	if strings.HasPrefix(args, `"`) {
+		if i := strings.LastIndexByte(args, '"'); i > 0 {
+			name = args[1:i]
+		} else {
+			name = args[1:]
+		}
+	} else if i := strings.LastIndexByte(args, ' '); i > 0 {
+		name = args[:i]
+	} else {
+		name = args
+	}
+
+	return strings.ReplaceAll(name, `\`, "/")
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseTime parses mm:ss:ff position.
+func parseTime(s string) (float64, bool) {
+	var parts = strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	var nums [3]int
+
+	for i, p := range parts {
+		var n, err = strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		nums[i] = n
+	}
+
+	return float64(nums[0]*60+nums[1]) + float64(nums[2])/framesPerSecond, true
+}
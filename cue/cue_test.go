@@ -0,0 +1,131 @@
+package cue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		name string
+		src  string
+		want *Sheet
+		err  error
+	}{
+		{
+			name: "album",
+			src: "\xef\xbb\xbfPERFORMER \"Band\"\r\n" +
+				"TITLE \"Album\"\r\n" +
+				"FILE \"Album.flac\" WAVE\r\n" +
+				"  TRACK 01 AUDIO\r\n" +
+				"    TITLE \"One\"\r\n" +
+				"    INDEX 01 00:00:00\r\n" +
+				"  TRACK 02 AUDIO\r\n" +
+				"    TITLE \"Two\"\r\n" +
+				"    PERFORMER \"Guest\"\r\n" +
+				"    INDEX 00 03:58:00\r\n" +
+				"    INDEX 01 04:00:37\r\n",
+			want: &Sheet{
+				Title:     "Album",
+				Performer: "Band",
+				Files: []File{{
+					Name: "Album.flac",
+					Tracks: []Track{
+						{Number: 1, Title: "One", Start: 0, End: 240 + 37.0/75},
+						{Number: 2, Title: "Two", Performer: "Guest", Start: 240 + 37.0/75},
+					},
+				}},
+			},
+		},
+		{
+			name: "several files",
+			src: "FILE \"CD1\\01.wav\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00:00\n" +
+				"FILE 02.wav WAVE\n" +
+				"TRACK 02 AUDIO\n" +
+				"INDEX 01 00:00:00\n",
+			want: &Sheet{
+				Files: []File{
+					{Name: "CD1/01.wav", Tracks: []Track{{Number: 1}}},
+					{Name: "02.wav", Tracks: []Track{{Number: 2}}},
+				},
+			},
+		},
+		{
+			name: "windows-1251",
+			src:  "TITLE \"\xc0\xeb\xfc\xe1\xee\xec\"\nFILE \"a.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\n",
+			want: &Sheet{
+				Title: "Альбом",
+				Files: []File{{Name: "a.flac", Tracks: []Track{{Number: 1}}}},
+			},
+		},
+		{
+			name: "track without index is dropped",
+			src: "FILE \"a.flac\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00:00\n" +
+				"TRACK 02 AUDIO\n" +
+				"TRACK 03 AUDIO\n" +
+				"INDEX 01 01:00:00\n",
+			want: &Sheet{
+				Files: []File{{Name: "a.flac", Tracks: []Track{
+					{Number: 1, End: 60},
+					{Number: 3, Start: 60},
+				}}},
+			},
+		},
+		{
+			name: "malformed index times are ignored",
+			src: "FILE \"a.flac\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00\n" +
+				"INDEX 01 00:-1:00\n" +
+				"INDEX 01 00:xx:00\n" +
+				"INDEX 01\n" +
+				"INDEX 01 00:01:00\n",
+			want: &Sheet{
+				Files: []File{{Name: "a.flac", Tracks: []Track{{Number: 1, Start: 1}}}},
+			},
+		},
+		{
+			name: "track before file is ignored",
+			src:  "TRACK 01 AUDIO\nINDEX 01 00:00:00\nFILE \"a.flac\" WAVE\nTRACK 02 AUDIO\nINDEX 01 00:00:00\n",
+			want: &Sheet{
+				Files: []File{{Name: "a.flac", Tracks: []Track{{Number: 2}}}},
+			},
+		},
+		{
+			name: "truncated lines",
+			src:  "FILE \"a.flac\nTRACK\nINDEX 01 00:00:00\nTITLE \"",
+			want: &Sheet{
+				Files: []File{{Name: "a.flac", Tracks: []Track{{Title: "\""}}}},
+			},
+		},
+		{
+			name: "no tracks",
+			src:  "TITLE \"Album\"\nFILE \"a.flac\" WAVE\n",
+			err:  ErrNoTracks,
+		},
+		{
+			name: "empty",
+			src:  "",
+			err:  ErrNoTracks,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sheet, err = Parse([]byte(tt.src))
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if !reflect.DeepEqual(sheet, tt.want) {
+				t.Errorf("sheet = %+v, want %+v", sheet, tt.want)
+			}
+		})
+	}
+}
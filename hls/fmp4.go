@@ -0,0 +1,178 @@
+package hls
+
+import (
+	"io"
+)
+
+// Flags of trun sample entries.
+const (
+	trunDataOffset        = 0x000001
+	trunSampleDuration    = 0x000100
+	trunSampleSize        = 0x000200
+	trunSampleFlags       = 0x000400
+	trunCompositionOffset = 0x000800
+
+	tfhdDefaultBaseIsMoof = 0x020000
+
+	sampleFlagsSync    = 0x02000000
+	sampleFlagsNonSync = 0x01010000
+)
+
+func box(typ string, payload ...[]byte) []byte {
+	var size = 8
+	for _, p := range payload {
+		size += len(p)
+	}
+
+	var b = make([]byte, 8, size)
+	be.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+
+	return b
+}
+
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	var vf = u32(flags)
+	vf[0] = version
+	return box(typ, append([][]byte{vf}, payload...)...)
+}
+
+func u32(v uint32) []byte {
+	var b = make([]byte, 4)
+	be.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	var b = make([]byte, 8)
+	be.PutUint64(b, v)
+	return b
+}
+
+// Init returns the initialization segment with track descriptions.
+func (m *Media) Init() []byte {
+	var ftyp = box("ftyp", []byte("iso6"), u32(0), []byte("iso6isommp41"))
+
+	var mvhd = fullBox("mvhd", 0, 0,
+		u32(0), u32(0), // Creation and modification times.
+		u32(1000), u32(0), // Timescale and duration.
+		u32(0x00010000), []byte{0x01, 0x00}, make([]byte, 10), // Rate, volume and reserved.
+		u32(0x00010000), u32(0), u32(0), u32(0), u32(0x00010000), u32(0), u32(0), u32(0), u32(0x40000000), // Unity matrix.
+		make([]byte, 24), // Pre-defined.
+		u32(m.tracks[len(m.tracks)-1].id+1),
+	)
+
+	var traks [][]byte
+	var trexs [][]byte
+
+	for _, t := range m.tracks {
+		var dinf = box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+
+		var stbl = box("stbl",
+			t.stsd,
+			fullBox("stts", 0, 0, u32(0)),
+			fullBox("stsc", 0, 0, u32(0)),
+			fullBox("stsz", 0, 0, u32(0), u32(0)),
+			fullBox("stco", 0, 0, u32(0)),
+		)
+
+		traks = append(traks, box("trak",
+			t.tkhd,
+			box("mdia", t.mdhd, t.hdlr, box("minf", t.mhd, dinf, stbl)),
+		))
+
+		trexs = append(trexs, fullBox("trex", 0, 0, u32(t.id), u32(1), u32(0), u32(0), u32(0)))
+	}
+
+	var moov = box("moov", append(append([][]byte{mvhd}, traks...), box("mvex", trexs...))...)
+
+	return append(ftyp, moov...)
+}
+
+// Segment returns the media segment n reading samples from the file.
+func (m *Media) Segment(n int, r io.ReaderAt) ([]byte, error) {
+	var off, size, err = m.Range(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var data = make([]byte, size)
+
+	read, err := r.ReadAt(data, off)
+	if err != nil && !(err == io.EOF && read == len(data)) {
+		return nil, err
+	}
+
+	var seg = m.segments[n]
+
+	// Sample data of tracks follow each other in mdat.
+	var mdat [][]byte
+	var trackData = make([]int, len(m.tracks))
+
+	for j, t := range m.tracks {
+		for _, s := range t.samples[seg.begin[j]:seg.end[j]] {
+			var pos = s.offset - off
+			mdat = append(mdat, data[pos:pos+int64(s.size)])
+			trackData[j] += int(s.size)
+		}
+	}
+
+	// Data offsets depend on the moof size, which doesn't depend on offsets values.
+	var moof = m.moof(n, make([]int, len(m.tracks)))
+
+	var offsets = make([]int, len(m.tracks))
+	var pos = len(moof) + 8
+	for j := range m.tracks {
+		offsets[j] = pos
+		pos += trackData[j]
+	}
+
+	moof = m.moof(n, offsets)
+
+	return append(moof, box("mdat", mdat...)...), nil
+}
+
+func (m *Media) moof(n int, offsets []int) []byte {
+	var seg = m.segments[n]
+	var trafs = [][]byte{fullBox("mfhd", 0, 0, u32(uint32(n+1)))}
+
+	for j, t := range m.tracks {
+		var samples = t.samples[seg.begin[j]:seg.end[j]]
+
+		var baseTime uint64
+		if len(samples) > 0 {
+			baseTime = samples[0].dts
+		}
+
+		var entries = make([]byte, 0, len(samples)*16)
+
+		for _, s := range samples {
+			var flags uint32 = sampleFlagsNonSync
+			if s.sync {
+				flags = sampleFlagsSync
+			}
+
+			entries = append(entries, u32(s.duration)...)
+			entries = append(entries, u32(s.size)...)
+			entries = append(entries, u32(flags)...)
+			entries = append(entries, u32(uint32(s.cto))...)
+		}
+
+		var trun = fullBox("trun", 1,
+			trunDataOffset|trunSampleDuration|trunSampleSize|trunSampleFlags|trunCompositionOffset,
+			u32(uint32(len(samples))), u32(uint32(offsets[j])), entries,
+		)
+
+		trafs = append(trafs, box("traf",
+			fullBox("tfhd", 0, tfhdDefaultBaseIsMoof, u32(t.id)),
+			fullBox("tfdt", 1, 0, u64(baseTime)),
+			trun,
+		))
+	}
+
+	return box("moof", trafs...)
+}
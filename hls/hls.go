@@ -0,0 +1,236 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported codecs, only H.264 and AAC are supported")
+	ErrMalformed   = errors.New("malformed mp4 file")
+	ErrNoSegment   = errors.New("segment is out of range")
+)
+
+// Target segment duration in seconds, segments are cut at video keyframes.
+const targetDuration = 6
+
+// Limits file range of a segment, samples of files not interleaved by time span most of the file.
+const maxSegmentSize = 128 << 20
+
+// Media is an MP4 file packaged into fragmented MP4 segments.
+type Media struct {
+	tracks   []*track
+	segments []segment
+}
+
+type segment struct {
+	duration float64
+	// Sample ranges of the segment by tracks.
+	begin, end []int
+}
+
+// Parse reads sample tables of the first H.264 video and AAC audio tracks of the MP4 file.
+func Parse(r io.ReaderAt, size int64) (*Media, error) {
+	var moov, err = readMoov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var video, audio *track
+	var skipped []string
+
+	err = eachBox(moov, func(typ string, box, payload []byte) error {
+		if typ != "trak" {
+			return nil
+		}
+
+		var t, err = parseTrak(payload)
+		if err != nil {
+			return err
+		}
+
+		var codec = sampleEntry(t.stsd)
+
+		// Tracks of other codecs are skipped, e.g. AC-3 dub next to AAC audio.
+		switch t.handler {
+		case "vide":
+			if codec != "avc1" && codec != "avc3" {
+				skipped = append(skipped, "video "+strconv.Quote(codec))
+			} else if video == nil {
+				video = t
+			}
+		case "soun":
+			if codec != "mp4a" {
+				skipped = append(skipped, "audio "+strconv.Quote(codec))
+			} else if audio == nil {
+				audio = t
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var m = &Media{}
+
+	// The first track leads segmentation.
+	for _, t := range []*track{video, audio} {
+		if t != nil && len(t.samples) > 0 {
+			m.tracks = append(m.tracks, t)
+		}
+	}
+
+	if len(m.tracks) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupported, strings.Join(skipped, ", "))
+		}
+		return nil, ErrUnsupported
+	}
+
+	m.segment()
+
+	for n := range m.segments {
+		var _, size, err = m.Range(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if size > maxSegmentSize {
+			return nil, fmt.Errorf("%w: samples of segment %d span %d bytes", ErrUnsupported, n, size)
+		}
+	}
+
+	return m, nil
+}
+
+// sampleEntry returns the type of the first sample description.
+func sampleEntry(stsd []byte) string {
+	// Box header, version and flags, entry count and entry size.
+	if len(stsd) < 24 {
+		return ""
+	}
+	return string(stsd[20:24])
+}
+
+func (m *Media) segment() {
+	var lead = m.tracks[0]
+
+	// Segment starts of the lead track.
+	var cuts = []int{0}
+	var next = float64(targetDuration)
+
+	for i, s := range lead.samples {
+		if i > 0 && s.sync && lead.time(i) >= next {
+			cuts = append(cuts, i)
+			next = lead.time(i) + targetDuration
+		}
+	}
+
+	for k, begin := range cuts {
+		var end = len(lead.samples)
+		if k+1 < len(cuts) {
+			end = cuts[k+1]
+		}
+
+		var seg = segment{
+			duration: lead.time(end) - lead.time(begin),
+			begin:    make([]int, len(m.tracks)),
+			end:      make([]int, len(m.tracks)),
+		}
+
+		seg.begin[0], seg.end[0] = begin, end
+
+		// Other tracks follow by time.
+		for j, t := range m.tracks[1:] {
+			seg.begin[j+1] = t.at(lead.time(begin))
+			seg.end[j+1] = len(t.samples)
+			if end < len(lead.samples) {
+				seg.end[j+1] = t.at(lead.time(end))
+			}
+		}
+
+		m.segments = append(m.segments, seg)
+	}
+}
+
+// at returns the index of the first sample at or after the time.
+func (t *track) at(seconds float64) int {
+	var dts = uint64(math.Round(seconds * float64(t.timescale)))
+
+	var lo, hi = 0, len(t.samples)
+	for lo < hi {
+		var mid = (lo + hi) / 2
+		if t.samples[mid].dts < dts {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo
+}
+
+// Segments returns count of media segments.
+func (m *Media) Segments() int {
+	return len(m.segments)
+}
+
+// Playlist returns the HLS media playlist referring init segment as "init.mp4" and media segments as "{n}.m4s".
+func (m *Media) Playlist() string {
+	var max float64
+	for _, seg := range m.segments {
+		max = math.Max(max, seg.duration)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString("#EXT-X-VERSION:7\n")
+	sb.WriteString("#EXT-X-TARGETDURATION:" + strconv.Itoa(int(math.Ceil(max))) + "\n")
+	sb.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	sb.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	sb.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	sb.WriteString("#EXT-X-MAP:URI=\"init.mp4\"\n")
+
+	for n, seg := range m.segments {
+		sb.WriteString("#EXTINF:" + strconv.FormatFloat(seg.duration, 'f', 3, 64) + ",\n")
+		sb.WriteString(strconv.Itoa(n) + ".m4s\n")
+	}
+
+	sb.WriteString("#EXT-X-ENDLIST\n")
+
+	return sb.String()
+}
+
+// Range returns the byte range of the file holding samples of the segment.
+func (m *Media) Range(n int) (off, size int64, err error) {
+	if n < 0 || n >= len(m.segments) {
+		return 0, 0, ErrNoSegment
+	}
+
+	var seg = m.segments[n]
+	var begin, end int64 = math.MaxInt64, 0
+
+	for j, t := range m.tracks {
+		for _, s := range t.samples[seg.begin[j]:seg.end[j]] {
+			if s.offset < begin {
+				begin = s.offset
+			}
+			if s.offset+int64(s.size) > end {
+				end = s.offset + int64(s.size)
+			}
+		}
+	}
+
+	if end == 0 {
+		return 0, 0, nil
+	}
+
+	return begin, end - begin, nil
+}
@@ -0,0 +1,341 @@
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Sample data of the test movie starts after ftyp and mdat headers.
+const mdatStart = 16 + 8
+
+func trak(id uint32, handler, codec string, timescale uint32, tables ...[]byte) []byte {
+	var stsd = fullBox("stsd", 0, 0, u32(1), box(codec, make([]byte, 8)))
+
+	return box("trak",
+		fullBox("tkhd", 0, 3, u32(0), u32(0), u32(id), make([]byte, 68)),
+		box("mdia",
+			fullBox("mdhd", 0, 0, u32(0), u32(0), u32(timescale), u32(0), make([]byte, 4)),
+			fullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 13)),
+			box("minf", fullBox("vmhd", 0, 1, make([]byte, 8)), box("stbl", append([][]byte{stsd}, tables...)...)),
+		),
+	)
+}
+
+func stts(count, delta uint32) []byte {
+	return fullBox("stts", 0, 0, u32(1), u32(count), u32(delta))
+}
+
+func stsz(size, count uint32, sizes ...uint32) []byte {
+	var b = [][]byte{u32(size), u32(count)}
+	for _, s := range sizes {
+		b = append(b, u32(s))
+	}
+	return fullBox("stsz", 0, 0, b...)
+}
+
+func stss(numbers ...uint32) []byte {
+	var b = [][]byte{u32(uint32(len(numbers)))}
+	for _, n := range numbers {
+		b = append(b, u32(n))
+	}
+	return fullBox("stss", 0, 0, b...)
+}
+
+func stsc(first, perChunk uint32) []byte {
+	return fullBox("stsc", 0, 0, u32(1), u32(first), u32(perChunk), u32(1))
+}
+
+func stco(offsets ...uint32) []byte {
+	var b = [][]byte{u32(uint32(len(offsets)))}
+	for _, o := range offsets {
+		b = append(b, u32(o))
+	}
+	return fullBox("stco", 0, 0, b...)
+}
+
+// videoTables describe 14 one-second samples of 10 bytes in one chunk with keyframes every 3 samples.
+func videoTables() [][]byte {
+	return [][]byte{stts(14, 1), stsz(10, 14), stss(1, 4, 7, 10, 13), stsc(1, 14), stco(mdatStart)}
+}
+
+// audioTables describe 14 one-second samples of 4 bytes following the video.
+func audioTables() [][]byte {
+	return [][]byte{stts(14, 1), stsz(4, 14), stsc(1, 14), stco(mdatStart + 140)}
+}
+
+func movie(traks ...[]byte) []byte {
+	var mdat = make([]byte, 14*10+14*4)
+	for i := range mdat {
+		mdat[i] = byte(i)
+	}
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0)),
+		box("mdat", mdat),
+		box("moov", traks...),
+	}, nil)
+}
+
+func replace(tables [][]byte, i int, table []byte) [][]byte {
+	var b = append([][]byte{}, tables...)
+	if table == nil {
+		return append(b[:i], b[i+1:]...)
+	}
+	b[i] = table
+	return b
+}
+
+// sparse is a file of the size with the data at the start followed by zeros.
+type sparse struct {
+	data []byte
+}
+
+func (s sparse) ReadAt(b []byte, off int64) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	if off < int64(len(s.data)) {
+		copy(b, s.data[off:])
+	}
+	return len(b), nil
+}
+
+func TestParse(t *testing.T) {
+	var video = trak(1, "vide", "avc1", 1, videoTables()...)
+	var audio = trak(2, "soun", "mp4a", 1, audioTables()...)
+
+	var tests = []struct {
+		name     string
+		data     []byte
+		size     int64
+		segments int
+		err      error
+	}{
+		{
+			name:     "video and audio",
+			data:     movie(video, audio),
+			segments: 3,
+		},
+		{
+			name:     "audio only",
+			data:     movie(audio),
+			segments: 3,
+		},
+		{
+			name:     "unsupported audio is skipped",
+			data:     movie(video, trak(2, "soun", "ac-3", 1, audioTables()...), trak(3, "soun", "mp4a", 1, audioTables()...)),
+			segments: 3,
+		},
+		{
+			name:     "video only with unsupported audio",
+			data:     movie(video, trak(2, "soun", "ac-3", 1, audioTables()...)),
+			segments: 3,
+		},
+		{
+			name:     "unsupported video is skipped",
+			data:     movie(trak(1, "vide", "hev1", 1, videoTables()...), audio),
+			segments: 3,
+		},
+		{
+			name:     "64-bit box size",
+			data:     bytes.Join([][]byte{movie(video), u32(1), []byte("free"), u64(16)}, nil),
+			segments: 3,
+		},
+		{
+			name: "no moov",
+			data: box("mdat", make([]byte, 100)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "moov beyond the file",
+			data: append(u32(1000), []byte("moov")...),
+			err:  ErrMalformed,
+		},
+		{
+			name: "moov larger than limit",
+			data: append(u32(1), []byte("moov\x00\x00\x00\x00\x08\x00\x00\x00")...),
+			size: 1 << 30,
+			err:  ErrMalformed,
+		},
+		{
+			name: "box of huge size",
+			data: append(box("ftyp", []byte("isom")), append(u32(1), []byte("free\x7f\xff\xff\xff\xff\xff\xff\xff")...)...),
+			err:  ErrMalformed,
+		},
+		{
+			name: "box smaller than its header",
+			data: movie(append(u32(4), []byte("trak")...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "nested box beyond the parent",
+			data: movie(append(u32(1000), []byte("trak")...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "unsupported codecs",
+			data: movie(trak(1, "vide", "hev1", 1, videoTables()...), trak(2, "soun", "ac-3", 1, audioTables()...)),
+			err:  ErrUnsupported,
+		},
+		{
+			name: "no tracks",
+			data: movie(trak(1, "text", "tx3g", 1, videoTables()...)),
+			err:  ErrUnsupported,
+		},
+		{
+			name: "zero timescale",
+			data: movie(trak(1, "vide", "avc1", 0, videoTables()...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "sample count over limit",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 1, stsz(10, maxSamples+1))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "truncated sample sizes",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 1, stsz(0, 14, 10, 10, 10))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "samples without decode times",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 0, stts(10, 1))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "truncated decode times",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 0, fullBox("stts", 0, 0, u32(2), u32(14), u32(1)))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "missing chunk offsets",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 4, nil)...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "chunks not covering samples",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 3, stsc(1, 10))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "invalid first chunk",
+			data: movie(trak(1, "vide", "avc1", 1, replace(videoTables(), 3, stsc(0, 14))...)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "segment span over limit",
+			data: movie(trak(1, "vide", "avc1", 1, stts(2, 1), stsz(10, 2), stsc(1, 1), stco(mdatStart, mdatStart+maxSegmentSize))),
+			err:  ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var size = tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+
+			var m, err = Parse(sparse{tt.data}, size)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			if m.Segments() != tt.segments {
+				t.Errorf("segments = %d, want %d", m.Segments(), tt.segments)
+			}
+		})
+	}
+}
+
+func TestMediaSegments(t *testing.T) {
+	var data = movie(
+		trak(1, "vide", "avc1", 1, videoTables()...),
+		trak(2, "soun", "mp4a", 1, audioTables()...),
+	)
+
+	var m, err = Parse(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var playlist = m.Playlist()
+	for _, line := range []string{"#EXT-X-TARGETDURATION:6\n", "#EXTINF:6.000,\n0.m4s\n", "#EXTINF:2.000,\n2.m4s\n", "#EXT-X-ENDLIST\n"} {
+		if !strings.Contains(playlist, line) {
+			t.Errorf("playlist misses %q:\n%s", line, playlist)
+		}
+	}
+
+	var ranges = []struct {
+		off, size int64
+		video     []int
+		audio     []int
+	}{
+		{off: mdatStart, size: 140 + 6*4, video: []int{0, 6}, audio: []int{0, 6}},
+		{off: mdatStart + 60, size: 80 + 12*4, video: []int{6, 12}, audio: []int{6, 12}},
+		{off: mdatStart + 120, size: 20 + 14*4, video: []int{12, 14}, audio: []int{12, 14}},
+	}
+
+	for n, want := range ranges {
+		var off, size, err = m.Range(n)
+		if err != nil || off != want.off || size != want.size {
+			t.Errorf("range %d = %d, %d, %v, want %d, %d", n, off, size, err, want.off, want.size)
+		}
+
+		seg, err := m.Segment(n, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("segment %d: %v", n, err)
+		}
+
+		var moof, mdat []byte
+		err = eachBox(seg, func(typ string, box, payload []byte) error {
+			switch typ {
+			case "moof":
+				moof = box
+			case "mdat":
+				mdat = payload
+			}
+			return nil
+		})
+		if err != nil || moof == nil || mdat == nil {
+			t.Fatalf("segment %d: moof %d bytes, mdat %d bytes, %v", n, len(moof), len(mdat), err)
+		}
+
+		// Video samples are followed by audio samples.
+		var wantData = append(
+			append([]byte{}, data[mdatStart+10*want.video[0]:mdatStart+10*want.video[1]]...),
+			data[mdatStart+140+4*want.audio[0]:mdatStart+140+4*want.audio[1]]...,
+		)
+		if !bytes.Equal(mdat, wantData) {
+			t.Errorf("segment %d: mdat = %v, want %v", n, mdat, wantData)
+		}
+
+		// Data offset of the first trun refers to the start of mdat payload.
+		var trun = bytes.Index(moof, []byte("trun"))
+		if trun < 0 || int(be.Uint32(moof[trun+12:])) != len(moof)+8 {
+			t.Errorf("segment %d: trun data offset doesn't refer mdat payload", n)
+		}
+	}
+
+	if _, _, err = m.Range(len(ranges)); !errors.Is(err, ErrNoSegment) {
+		t.Errorf("range out of segments: error = %v, want %v", err, ErrNoSegment)
+	}
+
+	// Samples beyond the end of the truncated file.
+	_, err = m.Segment(2, bytes.NewReader(data[:mdatStart+150]))
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("truncated segment: error = %v, want %v", err, io.EOF)
+	}
+
+	if math.Abs(m.tracks[0].time(14)-14) > 1e-9 {
+		t.Errorf("end time = %v, want 14", m.tracks[0].time(14))
+	}
+}
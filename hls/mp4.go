@@ -0,0 +1,393 @@
+package hls
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+var be = binary.BigEndian
+
+// Largest moov box read into memory.
+const maxMoovSize = 64 << 20
+
+// Limits samples of a track, it's hours of 60 fps video.
+const maxSamples = 1 << 22
+
+type sample struct {
+	offset   int64
+	size     uint32
+	dts      uint64
+	duration uint32
+	cto      int32
+	sync     bool
+}
+
+type track struct {
+	id        uint32
+	handler   string
+	timescale uint32
+
+	// Boxes copied to the init segment as is.
+	tkhd, mdhd, hdlr, mhd, stsd []byte
+
+	samples []sample
+}
+
+// time returns decode time of the sample in seconds.
+func (t *track) time(i int) float64 {
+	if i >= len(t.samples) {
+		var last = t.samples[len(t.samples)-1]
+		return float64(last.dts+uint64(last.duration)) / float64(t.timescale)
+	}
+	return float64(t.samples[i].dts) / float64(t.timescale)
+}
+
+// eachBox calls fn for every box of b with the box including its header and the payload.
+func eachBox(b []byte, fn func(typ string, box, payload []byte) error) error {
+	for len(b) >= 8 {
+		var size = uint64(be.Uint32(b))
+		var header = uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return ErrMalformed
+			}
+			size = be.Uint64(b[8:16])
+			header = 16
+		}
+
+		if size < header || size > uint64(len(b)) {
+			return ErrMalformed
+		}
+
+		var err = fn(string(b[4:8]), b[:size], b[header:size])
+		if err != nil {
+			return err
+		}
+
+		b = b[size:]
+	}
+
+	return nil
+}
+
+// readMoov reads the top level moov box of the file.
+func readMoov(r io.ReaderAt, size int64) ([]byte, error) {
+	var h = make([]byte, 16)
+
+	for off := int64(0); off+8 <= size; {
+		var _, err = r.ReadAt(h[:8], off)
+		if err != nil {
+			return nil, err
+		}
+
+		var boxSize = int64(be.Uint32(h))
+		var header = int64(8)
+
+		switch boxSize {
+		case 0:
+			boxSize = size - off
+		case 1:
+			_, err = r.ReadAt(h[8:16], off+8)
+			if err != nil {
+				return nil, err
+			}
+			boxSize = int64(be.Uint64(h[8:16]))
+			header = 16
+		}
+
+		if boxSize < header || boxSize > size-off {
+			return nil, ErrMalformed
+		}
+
+		if string(h[4:8]) == "moov" {
+			if boxSize-header > maxMoovSize {
+				return nil, ErrMalformed
+			}
+
+			var moov = make([]byte, boxSize-header)
+
+			_, err = r.ReadAt(moov, off+header)
+			if err != nil {
+				return nil, err
+			}
+
+			return moov, nil
+		}
+
+		off += boxSize
+	}
+
+	return nil, ErrMalformed
+}
+
+// sampleTables are raw sample table boxes of the track.
+type sampleTables struct {
+	stts, ctts, stss, stsz, stsc, stco, co64 []byte
+}
+
+func parseTrak(trak []byte) (*track, error) {
+	var t = &track{}
+	var st sampleTables
+
+	var err = eachBox(trak, func(typ string, box, payload []byte) error {
+		switch typ {
+		case "tkhd":
+			t.tkhd = box
+			if len(payload) < 24 {
+				return ErrMalformed
+			}
+			if payload[0] == 1 {
+				t.id = be.Uint32(payload[20:24])
+			} else {
+				t.id = be.Uint32(payload[12:16])
+			}
+
+		case "mdia":
+			return eachBox(payload, func(typ string, box, payload []byte) error {
+				switch typ {
+				case "mdhd":
+					t.mdhd = box
+					if len(payload) < 24 {
+						return ErrMalformed
+					}
+					if payload[0] == 1 {
+						t.timescale = be.Uint32(payload[20:24])
+					} else {
+						t.timescale = be.Uint32(payload[12:16])
+					}
+
+				case "hdlr":
+					t.hdlr = box
+					if len(payload) < 12 {
+						return ErrMalformed
+					}
+					t.handler = string(payload[8:12])
+
+				case "minf":
+					return parseMinf(t, &st, payload)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if t.timescale == 0 || t.stsd == nil {
+		return nil, ErrMalformed
+	}
+
+	t.samples, err = st.samples()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func parseMinf(t *track, st *sampleTables, minf []byte) error {
+	return eachBox(minf, func(typ string, box, payload []byte) error {
+		switch typ {
+		case "vmhd", "smhd":
+			t.mhd = box
+
+		case "stbl":
+			return eachBox(payload, func(typ string, box, payload []byte) error {
+				switch typ {
+				case "stsd":
+					t.stsd = box
+				case "stts":
+					st.stts = payload
+				case "ctts":
+					st.ctts = payload
+				case "stss":
+					st.stss = payload
+				case "stsz":
+					st.stsz = payload
+				case "stsc":
+					st.stsc = payload
+				case "stco":
+					st.stco = payload
+				case "co64":
+					st.co64 = payload
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// entries returns entries of the full box table with the entry count following version and flags.
+func entries(b []byte, skip, entrySize int) ([]byte, int, error) {
+	if len(b) < skip+8 {
+		return nil, 0, ErrMalformed
+	}
+
+	var count = int(be.Uint32(b[skip+4:]))
+	b = b[skip+8:]
+
+	if count < 0 || len(b) < count*entrySize {
+		return nil, 0, ErrMalformed
+	}
+
+	return b, count, nil
+}
+
+func (st *sampleTables) samples() ([]sample, error) {
+	// Sample sizes.
+	if len(st.stsz) < 12 {
+		return nil, ErrMalformed
+	}
+
+	var fixedSize = be.Uint32(st.stsz[4:8])
+	var count = int(be.Uint32(st.stsz[8:12]))
+
+	if count > maxSamples || fixedSize == 0 && len(st.stsz) < 12+count*4 {
+		return nil, ErrMalformed
+	}
+
+	// Decode times.
+	table, n, err := entries(st.stts, 0, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every sample has decode time.
+	var timed uint64
+	for e := 0; e < n; e++ {
+		timed += uint64(be.Uint32(table[e*8:]))
+	}
+
+	if uint64(count) > timed {
+		return nil, ErrMalformed
+	}
+
+	var samples = make([]sample, count)
+
+	for i := range samples {
+		samples[i].sync = st.stss == nil
+		if fixedSize != 0 {
+			samples[i].size = fixedSize
+		} else {
+			samples[i].size = be.Uint32(st.stsz[12+i*4:])
+		}
+	}
+
+	var i int
+	var dts uint64
+
+	for e := 0; e < n; e++ {
+		var sampleCount = int(be.Uint32(table[e*8:]))
+		var delta = be.Uint32(table[e*8+4:])
+
+		for j := 0; j < sampleCount && i < count; j++ {
+			samples[i].dts = dts
+			samples[i].duration = delta
+			dts += uint64(delta)
+			i++
+		}
+	}
+
+	// Composition offsets.
+	if st.ctts != nil {
+		table, n, err = entries(st.ctts, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		i = 0
+		for e := 0; e < n; e++ {
+			var sampleCount = int(be.Uint32(table[e*8:]))
+			var offset = int32(be.Uint32(table[e*8+4:]))
+
+			for j := 0; j < sampleCount && i < count; j++ {
+				samples[i].cto = offset
+				i++
+			}
+		}
+	}
+
+	// Sync samples.
+	if st.stss != nil {
+		table, n, err = entries(st.stss, 0, 4)
+		if err != nil {
+			return nil, err
+		}
+
+		for e := 0; e < n; e++ {
+			var number = int(be.Uint32(table[e*4:]))
+			if number >= 1 && number <= count {
+				samples[number-1].sync = true
+			}
+		}
+	}
+
+	// Sample offsets by chunks.
+	var chunks []int64
+
+	switch {
+	case st.co64 != nil:
+		table, n, err = entries(st.co64, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+		for e := 0; e < n; e++ {
+			chunks = append(chunks, int64(be.Uint64(table[e*8:])))
+		}
+
+	case st.stco != nil:
+		table, n, err = entries(st.stco, 0, 4)
+		if err != nil {
+			return nil, err
+		}
+		for e := 0; e < n; e++ {
+			chunks = append(chunks, int64(be.Uint32(table[e*4:])))
+		}
+
+	default:
+		return nil, ErrMalformed
+	}
+
+	stsc, n, err := entries(st.stsc, 0, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	i = 0
+	for e := 0; e < n && i < count; e++ {
+		var first = int(be.Uint32(stsc[e*12:])) - 1
+		var perChunk = int(be.Uint32(stsc[e*12+4:]))
+		var last = len(chunks)
+
+		if e+1 < n {
+			last = int(be.Uint32(stsc[(e+1)*12:])) - 1
+		}
+
+		if first < 0 || last > len(chunks) {
+			return nil, ErrMalformed
+		}
+
+		for c := first; c < last && i < count; c++ {
+			var off = chunks[c]
+
+			for j := 0; j < perChunk && i < count; j++ {
+				samples[i].offset = off
+				off += int64(samples[i].size)
+				i++
+			}
+		}
+	}
+
+	if i < count {
+		return nil, ErrMalformed
+	}
+
+	return samples, nil
+}
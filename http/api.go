@@ -1,19 +1,29 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
+	"github.com/asaskevich/govalidator"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/rs/zerolog/log"
 
 	"github.com/WinPooh32/peerstohttp/app"
+	"github.com/WinPooh32/peerstohttp/archive"
+	"github.com/WinPooh32/peerstohttp/hls"
 	"github.com/WinPooh32/peerstohttp/http/host"
 	list_render "github.com/WinPooh32/peerstohttp/http/render"
 	"github.com/WinPooh32/peerstohttp/playlist"
+	"github.com/WinPooh32/peerstohttp/thumbnail"
+	"github.com/WinPooh32/peerstohttp/transcode"
 )
 
 const (
@@ -22,19 +32,84 @@ const (
 	paramPath       = "path"
 	paramWhitelist  = "whitelist"
 	paramIgnoretags = "ignoretags"
+	paramArchives   = "archives"
+	paramSize       = "size"
+	paramProfile    = "profile"
+	paramOrder      = "order"
+	paramList       = "list"
+)
+
+const (
+	queryExt         = "ext"
+	queryExcludeTags = "exclude_tags"
+
+	querySort   = "sort"
+	queryOrder  = "order"
+	queryOffset = "offset"
+	queryLimit  = "limit"
+	queryCursor = "cursor"
+
+	queryFormat = "format"
+	queryDedup  = "dedup"
+
+	// Limits JSON body of the list request.
+	maxListBody = 1 << 20
+	// Limits torrents of the merged list.
+	maxListSources = 32
+)
+
+const (
+	queryVerified = "verified"
+
+	queryWarmHead    = "head"
+	queryWarmTail    = "tail"
+	queryWarmTimeout = "timeout"
+
+	// Default warm-up sizes in MiB and timeout in seconds.
+	defaultWarmHead    = 16
+	defaultWarmTail    = 4
+	defaultWarmTimeout = 60
 )
 
 var (
-	patternList = fmt.Sprintf("%s:[json,m3u,html]+", list_render.ParamContentType)
+	patternList = fmt.Sprintf("%s:[a-z0-9_-]+", list_render.ParamContentType)
 )
 
+// listRequest is the list request taken from the query parameters or the JSON body.
+type listRequest struct {
+	// Info hashes and magnet URIs of the torrents, items of several torrents are merged.
+	Hash    string   `json:"hash"`
+	Magnet  string   `json:"magnet"`
+	Hashes  []string `json:"hashes"`
+	Magnets []string `json:"magnets"`
+	// Drops items of the same file name and size from the merged list.
+	Dedup bool `json:"dedup"`
+
+	// Output format, negotiated by Accept header when empty.
+	Format string `json:"format"`
+
+	Ext         []string `json:"ext"`
+	ExcludeTags []string `json:"exclude_tags"`
+	Archives    bool     `json:"archives"`
+
+	Sort   string `json:"sort"`
+	Order  string `json:"order"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+}
+
 type handle struct {
 	app *app.App
+
+	// Limits concurrent thumbnails generation.
+	thumbs chan struct{}
 }
 
 func RouteApp(r chi.Router, app *app.App) {
 	var h = handle{
-		app: app,
+		app:    app,
+		thumbs: make(chan struct{}, runtime.NumCPU()),
 	}
 
 	r.Route("/list/{"+patternList+"}/{"+paramWhitelist+"}/{"+paramIgnoretags+"}/", func(r chi.Router) {
@@ -42,21 +117,45 @@ func RouteApp(r chi.Router, app *app.App) {
 			whitelist,
 			ignoretags,
 			host.Host,
-			list_render.ListContentType,
-			list_render.SetListResponder,
+			list_render.ListFormat,
 		)
 
-		r.With(hash).Get("/hash/{hash}", h.hash)
-		r.With(magnet).Get("/magnet/*", h.magnet)
+		r.With(hash, archives, order).Get("/hash/{hash}", h.hash)
+		r.With(magnet, archives, order).Get("/magnet/*", h.magnet)
 	})
 
+	r.With(listQuery, host.Host).Get("/list", h.list)
+	r.With(listBody, host.Host).Post("/list", h.list)
+	r.With(listBody, host.Host).Post("/list/merge", h.list)
+
 	r.With(hash, path).Get("/content/{"+paramHash+"}/*", h.content)
+
+	r.With(hash, path, queryFilters, host.Host).Get("/player/{"+paramHash+"}/*", h.player)
+
+	r.With(hash, queryFilters, host.Host).Get("/feed/{"+paramHash+"}.rss", h.feed)
+	r.With(hash, queryFilters, host.Host).Get("/export/kodi/{"+paramHash+"}.zip", h.kodi)
+	r.With(hash, path).Get("/stats/{"+paramHash+"}/*", h.stats)
+
+	r.With(hash, path).Get("/thumbnail/{"+paramSize+"}/{"+paramHash+"}/*", h.thumbnail)
+
+	r.With(hash, path).Get("/subtitles/{"+paramHash+"}/*", h.subtitles)
+
+	r.With(hash, path).Get("/cover/{"+paramHash+"}/*", h.cover)
+
+	r.With(hash, path).Get("/hls/{"+paramHash+"}/*", h.hls)
+
+	r.With(hash, path).Get("/transcode/{"+paramProfile+"}/{"+paramHash+"}/*", h.transcode)
+
+	r.With(hash, path).Post("/warm/{"+paramHash+"}/*", h.warm)
+	r.With(hash, path).Get("/warm/{"+paramHash+"}/*", h.warmState)
 }
 
 func (h *handle) hash(w http.ResponseWriter, r *http.Request) {
 	var hash = r.Context().Value(paramHash).(string)
 	var whitelist = r.Context().Value(paramWhitelist).(map[string]struct{})
 	var ignoretags = r.Context().Value(paramIgnoretags).(map[string]struct{})
+	var archives = r.Context().Value(paramArchives).(bool)
+	var order = r.Context().Value(paramOrder).(playlist.Order)
 
 	var t, err = h.app.TrackHashContext(r.Context(), metainfo.NewHashFromHex(hash))
 	if err != nil {
@@ -65,13 +164,18 @@ func (h *handle) hash(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.Render(w, r, &playlist.PlayList{Torr: t, Whitelist: whitelist, IgnoreTags: ignoretags})
+	var list = h.playlist(r, t, whitelist, ignoretags, archives)
+	list.Order = order
+
+	list_render.List(w, r, list)
 }
 
 func (h *handle) magnet(w http.ResponseWriter, r *http.Request) {
 	var magnet = r.Context().Value(paramMagnet).(*metainfo.Magnet)
 	var whitelist = r.Context().Value(paramWhitelist).(map[string]struct{})
 	var ignoretags = r.Context().Value(paramIgnoretags).(map[string]struct{})
+	var archives = r.Context().Value(paramArchives).(bool)
+	var order = r.Context().Value(paramOrder).(playlist.Order)
 
 	var t, err = h.app.TrackMagnetContext(r.Context(), magnet)
 	if err != nil {
@@ -80,7 +184,115 @@ func (h *handle) magnet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.Render(w, r, &playlist.PlayList{Torr: t, Whitelist: whitelist, IgnoreTags: ignoretags})
+	var list = h.playlist(r, t, whitelist, ignoretags, archives)
+	list.Order = order
+
+	list_render.List(w, r, list)
+}
+
+func (h *handle) list(w http.ResponseWriter, r *http.Request) {
+	var req = r.Context().Value(paramList).(*listRequest)
+
+	var format, ok = list_render.Lookup(req.Format)
+
+	switch {
+	case req.Format == "":
+		format = list_render.Negotiate(r.Header.Get("Accept"))
+		w.Header().Add("Vary", "Accept")
+	case !ok:
+		http.Error(w, "unknown format", http.StatusBadRequest)
+		return
+	}
+
+	var order, err = parseOrder(req.Sort, req.Order, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	torrents, ok := h.trackList(w, r, req)
+	if !ok {
+		return
+	}
+
+	var whitelist = parseWhitelist(strings.Join(req.Ext, ","))
+	var ignoretags = parseIgnoretags(strings.Join(req.ExcludeTags, ","))
+
+	var list = h.playlist(r, torrents[0], whitelist, ignoretags, req.Archives)
+	list.Merge = torrents[1:]
+	list.Dedup = req.Dedup
+	list.Order = order
+
+	var ctx = list_render.WithFormat(r.Context(), format)
+
+	// Pages of the body request are linked as GET requests.
+	if r.Method != http.MethodGet {
+		var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+		ctx = list_render.WithPageURL(ctx, origin+"/list?"+req.query().Encode())
+	}
+
+	list_render.List(w, r.WithContext(ctx), list)
+}
+
+// trackList tracks torrents of the list request.
+// It writes an error response when a torrent is not available.
+func (h *handle) trackList(w http.ResponseWriter, r *http.Request, req *listRequest) ([]*torrent.Torrent, bool) {
+	var hashes = req.Hashes
+	if req.Hash != "" {
+		hashes = append([]string{req.Hash}, hashes...)
+	}
+
+	var magnets = req.Magnets
+	if req.Magnet != "" {
+		magnets = append([]string{req.Magnet}, magnets...)
+	}
+
+	switch n := len(hashes) + len(magnets); {
+	case n == 0:
+		http.Error(w, "hash or magnet is required", http.StatusBadRequest)
+		return nil, false
+	case n > maxListSources:
+		http.Error(w, fmt.Sprintf("too many torrents, %d at most", maxListSources), http.StatusBadRequest)
+		return nil, false
+	}
+
+	var torrents = make([]*torrent.Torrent, 0, len(hashes)+len(magnets))
+
+	for _, hash := range hashes {
+		hash = strings.ToLower(hash)
+		if !govalidator.IsSHA1(hash) {
+			http.Error(w, "malformed hash", http.StatusBadRequest)
+			return nil, false
+		}
+
+		var t, err = h.app.TrackHashContext(r.Context(), metainfo.NewHashFromHex(hash))
+		if err != nil {
+			log.Error().Err(err).Msg("track by hash")
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return nil, false
+		}
+
+		torrents = append(torrents, t)
+	}
+
+	for _, uri := range magnets {
+		var magnet, err = metainfo.ParseMagnetUri(uri)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+
+		t, err := h.app.TrackMagnetContext(r.Context(), &magnet)
+		if err != nil {
+			log.Error().Err(err).Msg("track by magnet")
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return nil, false
+		}
+
+		torrents = append(torrents, t)
+	}
+
+	return torrents, true
 }
 
 func (h *handle) content(w http.ResponseWriter, r *http.Request) {
@@ -89,14 +301,138 @@ func (h *handle) content(w http.ResponseWriter, r *http.Request) {
 	var hash = r.Context().Value(paramHash).(string)
 	var path = r.Context().Value(paramPath).(string)
 
-	var t, ok = h.app.Client().Torrent(metainfo.NewHashFromHex(hash))
+	verified, err := verifiedParam(r, h.app)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	var t, ok = h.torrentInfo(w, r, hash)
 	if !ok {
-		t, ok = addNewTorrentHash(r.Context(), h.app, hash)
-		if !ok {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
+		return
+	}
+
+	if arc, entry, ok := archive.Split(path); ok {
+		err = serveArchiveEntry(w, r, h.app, t, arc, entry, verified)
+	} else if t.Info().IsDir() && strings.Count(path, "/") == 0 {
+		err = serveTorrentDir(w, r, t, path)
+	} else {
+		err = serveTorrentFile(w, r, h.app, t, path, verified)
+	}
+
+	if err != nil {
+		log.Warn().Err(err).Msg("serve content")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *handle) player(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+	var whitelist = r.Context().Value(paramWhitelist).(map[string]struct{})
+	var ignoretags = r.Context().Value(paramIgnoretags).(map[string]struct{})
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+
+	var list = &playlist.PlayList{Origin: origin, Torr: t, Whitelist: whitelist, IgnoreTags: ignoretags}
+
+	var err = list.Render(w, r)
+	if err != nil {
+		log.Error().Err(err).Msg("player playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	list_render.Player(w, r, list, path)
+}
+
+func (h *handle) feed(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var whitelist = r.Context().Value(paramWhitelist).(map[string]struct{})
+	var ignoretags = r.Context().Value(paramIgnoretags).(map[string]struct{})
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var list = h.playlist(r, t, whitelist, ignoretags, false)
+
+	var err = list.Render(w, r)
+	if err != nil {
+		log.Error().Err(err).Msg("feed playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	list_render.Feed(w, r, list)
+}
+
+func (h *handle) kodi(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var whitelist = r.Context().Value(paramWhitelist).(map[string]struct{})
+	var ignoretags = r.Context().Value(paramIgnoretags).(map[string]struct{})
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var list = h.playlist(r, t, whitelist, ignoretags, false)
+
+	var err = list.Render(w, r)
+	if err != nil {
+		log.Error().Err(err).Msg("kodi playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	list_render.Kodi(w, r, list)
+}
+
+func (h *handle) stats(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	if path == "" {
+		render.JSON(w, r, h.app.Stats(t, nil))
+		return
+	}
+
+	file, _, ok := findFile(t, path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	render.JSON(w, r, h.app.Stats(t, file))
+}
+
+func (h *handle) thumbnail(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+	var sizeName = chi.URLParam(r, paramSize)
+
+	var size, err = thumbnail.Size(sizeName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
 	}
 
 	select {
@@ -104,20 +440,232 @@ func (h *handle) content(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
 		return
 
-	case <-t.GotInfo():
+	case h.thumbs <- struct{}{}:
 	}
+	defer func() { <-h.thumbs }()
 
-	if t.Info().IsDir() && strings.Count(path, "/") == 0 {
-		err = serveTorrentDir(w, r, t, path)
+	err = serveThumbnail(w, r, h.app, t, path, sizeName, size)
+	if err != nil {
+		log.Warn().Err(err).Msg("serve thumbnail")
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+}
+
+func (h *handle) subtitles(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var err = serveSubtitles(w, r, t, path)
+	if err != nil {
+		log.Warn().Err(err).Msg("serve subtitles")
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+}
+
+func (h *handle) cover(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var err = serveCover(w, r, h.app, t, path)
+	if err != nil {
+		log.Warn().Err(err).Msg("serve cover")
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+}
+
+func (h *handle) hls(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	// Path of the file followed by the playlist or segment name.
+	var i = strings.LastIndexByte(path, '/')
+	if i < 0 {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	var err = serveHLS(w, r, h.app, t, path[:i], path[i+1:])
+	switch {
+	case err == nil:
+	case errors.Is(err, hls.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		log.Warn().Err(err).Msg("serve hls")
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+}
+
+func (h *handle) transcode(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+	var profileName = chi.URLParam(r, paramProfile)
+
+	var transcoder = h.app.Transcoder()
+	if transcoder == nil {
+		http.Error(w, "transcoding is not configured", http.StatusNotFound)
+		return
+	}
+
+	verified, err := verifiedParam(r, h.app)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	file, _, ok := findFile(t, path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var profile *transcode.Profile
+
+	if profileName == transcode.ProfileAuto {
+		profile, ok = transcoder.Match(r.UserAgent(), filepath.Ext(file.Path()))
+		if !ok {
+			// The client plays the file as is.
+			err = serveTorrentFile(w, r, h.app, t, path, verified)
+			if err != nil {
+				log.Warn().Err(err).Msg("serve content")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
 	} else {
-		err = serveTorrentFile(w, r, t, path)
+		profile, ok = transcoder.Profile(profileName)
+		if !ok {
+			http.Error(w, "unknown transcoding profile", http.StatusNotFound)
+			return
+		}
 	}
 
+	err = serveTranscoded(w, r, h.app, file, profile, verified)
 	if err != nil {
-		log.Warn().Err(err).Msg("serve content")
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Warn().Err(err).Str("profile", profile.Name).Msg("transcode")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *handle) warm(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
 		return
 	}
+
+	file, _, ok := findFile(t, path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var head, tail, timeout, err = warmParams(r, file.Length())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	render.JSON(w, r, h.app.Warm(file, head, tail, timeout))
+}
+
+func (h *handle) warmState(w http.ResponseWriter, r *http.Request) {
+	var hash = r.Context().Value(paramHash).(string)
+	var path = r.Context().Value(paramPath).(string)
+
+	var t, ok = h.torrentInfo(w, r, hash)
+	if !ok {
+		return
+	}
+
+	file, _, ok := findFile(t, path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	state, ok := h.app.WarmState(file)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	render.JSON(w, r, state)
+}
+
+// playlist makes the playlist of the torrent probing media info when enabled.
+func (h *handle) playlist(r *http.Request, t *torrent.Torrent, whitelist, ignoretags map[string]struct{}, archives bool) *playlist.PlayList {
+	var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+
+	var list = &playlist.PlayList{
+		Origin:     origin,
+		Torr:       t,
+		Whitelist:  whitelist,
+		IgnoreTags: ignoretags,
+		Archives:   archives,
+	}
+
+	var settings = h.app.Settings()
+
+	if timeout := *settings.ProbeTimeout; timeout > 0 {
+		list.ProbeTimeout = time.Duration(timeout) * time.Second
+	}
+	if timeout := *settings.TagsTimeout; timeout > 0 {
+		list.TagsTimeout = time.Duration(timeout) * time.Second
+	}
+
+	list.Probe = h.app
+
+	return list
+}
+
+// torrentInfo returns the torrent by hash adding it when required and waits for its info.
+// It writes an error response when the torrent is not available.
+func (h *handle) torrentInfo(w http.ResponseWriter, r *http.Request, hash string) (*torrent.Torrent, bool) {
+	var t, ok = h.app.Client().Torrent(metainfo.NewHashFromHex(hash))
+
+	if !ok {
+		t, ok = addNewTorrentHash(r.Context(), h.app, hash)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return nil, false
+		}
+	}
+
+	select {
+	case <-r.Context().Done():
+		http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+		return nil, false
+
+	case <-t.GotInfo():
+	}
+
+	return t, true
 }
 
 //func fileInfoHeader(fi *torrent.File) (*zip.FileHeader, error) {
@@ -2,13 +2,18 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/asaskevich/govalidator"
 	"github.com/go-chi/chi"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
 )
 
 func hash(next http.Handler) http.Handler {
@@ -59,36 +64,287 @@ func path(next http.Handler) http.Handler {
 	})
 }
 
-func whitelist(next http.Handler) http.Handler {
+func archives(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var whitelist = map[string]struct{}{}
-		var p = chi.URLParam(r, paramWhitelist)
+		var archives bool
+		var p = r.URL.Query().Get(paramArchives)
 
-		if p != "-" {
-			var args = strings.Split(strings.ToLower(p), ",")
-			for _, v := range args {
-				whitelist["."+v] = struct{}{}
+		if p != "" {
+			var err error
+
+			archives, err = strconv.ParseBool(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 		}
 
-		ctx := context.WithValue(r.Context(), paramWhitelist, whitelist)
+		ctx := context.WithValue(r.Context(), paramArchives, archives)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func ignoretags(next http.Handler) http.Handler {
+// order takes sorting and paging of the list from the query parameters.
+func order(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var ignoretags = map[string]struct{}{}
-		var p = chi.URLParam(r, paramIgnoretags)
+		var q = r.URL.Query()
+
+		var offset, err = parseCount(q.Get(queryOffset))
+		if err != nil {
+			http.Error(w, "offset: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		if p != "-" {
-			var args = strings.Split(strings.ToLower(p), ",")
-			for _, v := range args {
-				ignoretags[v] = struct{}{}
+		limit, err := parseCount(q.Get(queryLimit))
+		if err != nil {
+			http.Error(w, "limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		order, err := parseOrder(q.Get(querySort), q.Get(queryOrder), offset, limit, q.Get(queryCursor))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), paramOrder, order)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// listQuery takes the list request from the query parameters.
+func listQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q = r.URL.Query()
+		var req = listRequest{
+			Hashes:      q[paramHash],
+			Magnets:     q[paramMagnet],
+			Format:      q.Get(queryFormat),
+			Ext:         splitList(q.Get(queryExt)),
+			ExcludeTags: splitList(q.Get(queryExcludeTags)),
+			Sort:        q.Get(querySort),
+			Order:       q.Get(queryOrder),
+			Cursor:      q.Get(queryCursor),
+		}
+
+		var err error
+
+		if p := q.Get(paramArchives); p != "" {
+			req.Archives, err = strconv.ParseBool(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 		}
 
+		if p := q.Get(queryDedup); p != "" {
+			req.Dedup, err = strconv.ParseBool(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		req.Offset, err = parseCount(q.Get(queryOffset))
+		if err != nil {
+			http.Error(w, "offset: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.Limit, err = parseCount(q.Get(queryLimit))
+		if err != nil {
+			http.Error(w, "limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), paramList, &req)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// listBody takes the list request from the JSON body.
+func listBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req listRequest
+
+		var err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxListBody)).Decode(&req)
+		if err != nil {
+			http.Error(w, "decode request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), paramList, &req)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// query returns the query parameters of the GET list request equal to the request.
+func (req *listRequest) query() url.Values {
+	var q = url.Values{}
+
+	var hashes = req.Hashes
+	if req.Hash != "" {
+		hashes = append([]string{req.Hash}, hashes...)
+	}
+
+	var magnets = req.Magnets
+	if req.Magnet != "" {
+		magnets = append([]string{req.Magnet}, magnets...)
+	}
+
+	for _, hash := range hashes {
+		q.Add(paramHash, hash)
+	}
+	for _, magnet := range magnets {
+		q.Add(paramMagnet, magnet)
+	}
+
+	var params = []struct {
+		key, value string
+	}{
+		{queryFormat, req.Format},
+		{queryExt, strings.Join(req.Ext, ",")},
+		{queryExcludeTags, strings.Join(req.ExcludeTags, ",")},
+		{querySort, req.Sort},
+		{queryOrder, req.Order},
+		{queryCursor, req.Cursor},
+	}
+
+	for _, p := range params {
+		if p.value != "" {
+			q.Set(p.key, p.value)
+		}
+	}
+
+	if req.Archives {
+		q.Set(paramArchives, "true")
+	}
+	if req.Dedup {
+		q.Set(queryDedup, "true")
+	}
+	if req.Offset > 0 {
+		q.Set(queryOffset, strconv.Itoa(req.Offset))
+	}
+	if req.Limit > 0 {
+		q.Set(queryLimit, strconv.Itoa(req.Limit))
+	}
+
+	return q
+}
+
+func whitelist(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var whitelist = parseWhitelist(chi.URLParam(r, paramWhitelist))
+
+		ctx := context.WithValue(r.Context(), paramWhitelist, whitelist)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func ignoretags(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ignoretags = parseIgnoretags(chi.URLParam(r, paramIgnoretags))
+
 		ctx := context.WithValue(r.Context(), paramIgnoretags, ignoretags)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// queryFilters takes whitelist and ignoretags from the query parameters.
+func queryFilters(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q = r.URL.Query()
+
+		ctx := context.WithValue(r.Context(), paramWhitelist, parseWhitelist(q.Get(queryExt)))
+		ctx = context.WithValue(ctx, paramIgnoretags, parseIgnoretags(q.Get(queryExcludeTags)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func parseWhitelist(p string) map[string]struct{} {
+	var whitelist = map[string]struct{}{}
+
+	if p != "-" && p != "" {
+		var args = strings.Split(strings.ToLower(p), ",")
+		for _, v := range args {
+			whitelist["."+v] = struct{}{}
+		}
+	}
+
+	return whitelist
+}
+
+func parseIgnoretags(p string) map[string]struct{} {
+	var ignoretags = map[string]struct{}{}
+
+	if p != "-" && p != "" {
+		var args = strings.Split(strings.ToLower(p), ",")
+		for _, v := range args {
+			ignoretags[v] = struct{}{}
+		}
+	}
+
+	return ignoretags
+}
+
+// parseCount parses the non-negative number, empty string is zero.
+func parseCount(p string) (int, error) {
+	if p == "" {
+		return 0, nil
+	}
+
+	var n, err = strconv.Atoi(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 {
+		return 0, errors.New("negative value")
+	}
+
+	return n, nil
+}
+
+// parseOrder validates sorting and paging of the list, the cursor takes precedence over the offset.
+func parseOrder(sort, dir string, offset, limit int, cursor string) (playlist.Order, error) {
+	var order = playlist.Order{
+		Sort:   sort,
+		Offset: offset,
+		Limit:  limit,
+	}
+
+	if sort != "" && !playlist.IsSortKey(sort) {
+		return order, errors.New("unknown sort key")
+	}
+
+	switch dir {
+	case "", "asc":
+	case "desc":
+		order.Desc = true
+	default:
+		return order, errors.New("unknown sort order")
+	}
+
+	if offset < 0 || limit < 0 {
+		return order, errors.New("negative offset or limit")
+	}
+
+	if cursor != "" {
+		var err error
+
+		order.Offset, err = playlist.DecodeCursor(cursor)
+		if err != nil {
+			return order, err
+		}
+	}
+
+	return order, nil
+}
+
+// splitList splits the comma separated list, "-" means an empty list.
+func splitList(p string) []string {
+	if p == "" || p == "-" {
+		return nil
+	}
+	return strings.Split(p, ",")
+}
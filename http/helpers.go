@@ -1,21 +1,37 @@
 package http
 
 import (
+	"archive/zip"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/anacrolix/missinggo/v2"
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/rs/zerolog/log"
 
 	"github.com/WinPooh32/peerstohttp/app"
+	"github.com/WinPooh32/peerstohttp/archive"
+	"github.com/WinPooh32/peerstohttp/playlist"
+	"github.com/WinPooh32/peerstohttp/subtitle"
+	"github.com/WinPooh32/peerstohttp/thumbnail"
+	"github.com/WinPooh32/peerstohttp/transcode"
 )
 
-var errFileNotFound = errors.New("file not found")
+var (
+	errFileNotFound = errors.New("file not found")
+	errNotVerified  = errors.New("piece is not verified in time")
+)
 
 func addNewTorrentHash(ctx context.Context, app *app.App, hash string) (*torrent.Torrent, bool) {
 	var t, new = app.Client().AddTorrentInfoHash(metainfo.NewHashFromHex(hash))
@@ -96,21 +112,22 @@ func serveTorrentDir(w http.ResponseWriter, r *http.Request, t *torrent.Torrent,
 	return err
 }
 
-func serveTorrentFile(w http.ResponseWriter, r *http.Request, t *torrent.Torrent, path string) error {
+func serveTorrentFile(w http.ResponseWriter, r *http.Request, app *app.App, t *torrent.Torrent, path string, verified bool) error {
 	var name string
 
 	var file *torrent.File
+	var index int
 	var ok bool
 
 	log.Info().Msgf("file path = %s", path)
 
-	file, ok = findFile(t, path)
+	file, index, ok = findFile(t, path)
 
 	if !ok || file == nil {
 		return errFileNotFound
 	}
 
-	var reader = file.NewReader()
+	var reader = newFileReader(r, app, file, verified)
 	defer reader.Close()
 
 	fip := file.FileInfo().Path
@@ -122,38 +139,477 @@ func serveTorrentFile(w http.ResponseWriter, r *http.Request, t *torrent.Torrent
 		name = fip[len(fip)-1]
 	}
 
-	return serveContent(w, r, file.Length(), reader, name)
+	setCacheHeaders(w, etag(t, index), file)
+
+	var err = serveContent(w, r, file.Length(), reader, name, modTime(t))
+	if err == nil && notVerified(reader) {
+		abortResponse(w)
+	}
+
+	return err
+}
+
+// verifiedParam reports whether only hash checked data must be served.
+func verifiedParam(r *http.Request, app *app.App) (bool, error) {
+	var v = r.URL.Query().Get(queryVerified)
+	if v == "" {
+		return *app.Settings().Verified, nil
+	}
+
+	var verified, err = strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("malformed %s value: %q", queryVerified, v)
+	}
+
+	return verified, nil
 }
 
-func findFile(t *torrent.Torrent, path string) (*torrent.File, bool) {
+// warmParams parses warm-up sizes in MiB and timeout in seconds from the query.
+// Sizes are capped at the file length.
+func warmParams(r *http.Request, length int64) (head, tail int64, timeout time.Duration, err error) {
+	const mib = 1 << 20
+
+	var q = r.URL.Query()
+	var values = [3]int64{defaultWarmHead, defaultWarmTail, defaultWarmTimeout}
+
+	for i, key := range []string{queryWarmHead, queryWarmTail, queryWarmTimeout} {
+		var v = q.Get(key)
+		if v == "" {
+			continue
+		}
+
+		values[i], err = strconv.ParseInt(v, 10, 64)
+		if err != nil || values[i] < 0 {
+			return 0, 0, 0, fmt.Errorf("malformed %s value: %q", key, v)
+		}
+	}
+
+	var sizes [2]int64
+	for i := range sizes {
+		if values[i] > length/mib {
+			sizes[i] = length
+		} else {
+			sizes[i] = values[i] * mib
+		}
+	}
+
+	if values[2] == 0 {
+		return 0, 0, 0, fmt.Errorf("%s must be positive", queryWarmTimeout)
+	}
+
+	timeout = math.MaxInt64
+	if values[2] < int64(timeout/time.Second) {
+		timeout = time.Duration(values[2]) * time.Second
+	}
+
+	return sizes[0], sizes[1], timeout, nil
+}
+
+// newFileReader makes a reader of the file for streaming.
+func newFileReader(r *http.Request, app *app.App, file *torrent.File, verified bool) torrent.Reader {
+	// Coordinate readahead with concurrent streams of the file.
+	var reader = app.NewReader(file)
+
+	// Stream follows the warm-up, keep prioritized pieces while the file is streamed.
+	app.Streamed(file)
+
+	if verified {
+		var timeout = time.Duration(*app.Settings().VerifiedTimeout) * time.Second
+		return &verifiedReader{Reader: reader, ctx: r.Context(), file: file, timeout: timeout}
+	}
+
+	// Don't wait for pieces to complete and be verified.
+	reader.SetResponsive()
+
+	return reader
+}
+
+func serveArchiveEntry(w http.ResponseWriter, r *http.Request, app *app.App, t *torrent.Torrent, path, entryPath string, verified bool) error {
+	var file, index, ok = findFile(t, path)
+	if !ok || file == nil {
+		return errFileNotFound
+	}
+
+	var reader = newFileReader(r, app, file, verified)
+
+	var arc, err = archive.Open(r.Context(), reader, file.Length())
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer arc.Close()
+
+	entry, err := arc.Entry(entryPath)
+	if err != nil {
+		return err
+	}
+
+	offset, err := entry.DataOffset()
+	if err != nil {
+		return fmt.Errorf("archive entry offset: %w", err)
+	}
+
+	var name = filepath.Base(entry.Name)
+	var size = int64(entry.UncompressedSize64)
+
+	if size > 0 {
+		// Read ahead 10% of entry.
+		reader.SetReadahead((size * 10) / 100)
+	}
+
+	setCacheHeaders(w, etag(t, index, strconv.FormatInt(offset, 10)), file)
+	w.Header().Set("Content-Disposition", `filename="`+url.PathEscape(name)+`"`)
+
+	// Stored entries are sliced from the archive as is, so ranges are supported.
+	if entry.Method == zip.Store {
+		section, err := arc.Section(entry)
+		if err != nil {
+			return err
+		}
+
+		setContentType(w, name)
+
+		http.ServeContent(w, r, name, entry.Modified, section)
+
+		if notVerified(reader) {
+			abortResponse(w)
+		}
+		return nil
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" && match == w.Header().Get("ETag") {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("open archive entry: %w", err)
+	}
+	defer rc.Close()
+
+	setContentType(w, name)
+
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		log.Warn().Err(err).Msg("copy archive entry")
+	}
+
+	if notVerified(reader) {
+		abortResponse(w)
+	}
+
+	return nil
+}
+
+// serveSubtitles converts the subtitles file to WebVTT.
+func serveSubtitles(w http.ResponseWriter, r *http.Request, t *torrent.Torrent, path string) error {
+	const maxSize = 16 << 20
+
+	var file, index, ok = findFile(t, path)
+	if !ok || file == nil {
+		return errFileNotFound
+	}
+
+	var ext = filepath.Ext(file.Path())
+	if !subtitle.IsSubtitle(ext) {
+		return subtitle.ErrUnsupported
+	}
+
+	if file.Length() > maxSize {
+		return fmt.Errorf("subtitles file is too large: %d bytes", file.Length())
+	}
+
+	var tag = etag(t, index, "vtt")
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	var reader = file.NewReader()
+	defer reader.Close()
+
+	var src, err = io.ReadAll(missinggo.ContextedReader{R: reader, Ctx: r.Context()})
+	if err != nil {
+		return fmt.Errorf("read subtitles: %w", err)
+	}
+
+	vtt, err := subtitle.ToWebVTT(src, ext)
+	if err != nil {
+		return err
+	}
+
+	setCacheHeaders(w, tag, file)
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+
+	_, err = io.WriteString(w, vtt)
+	if err != nil {
+		log.Warn().Err(err).Msg("write subtitles")
+	}
+
+	return nil
+}
+
+// serveThumbnail serves cached JPEG thumbnail of the image file generating it when required.
+func serveThumbnail(w http.ResponseWriter, r *http.Request, app *app.App, t *torrent.Torrent, path, sizeName string, size int) error {
+	const maxSize = 64 << 20
+
+	var file, index, ok = findFile(t, path)
+	if !ok || file == nil {
+		return errFileNotFound
+	}
+
+	if !playlist.HasThumbnail(mime.TypeByExtension(filepath.Ext(file.Path()))) {
+		return fmt.Errorf("thumbnails are not supported: %s", file.Path())
+	}
+
+	var tag = etag(t, index, "thumbnail", sizeName)
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	data, ok := app.Thumbnail(t.InfoHash(), index, sizeName)
+	if !ok {
+		if file.Length() > maxSize {
+			return fmt.Errorf("image file is too large: %d bytes", file.Length())
+		}
+
+		var reader = file.NewReader()
+		defer reader.Close()
+
+		var err error
+
+		data, err = thumbnail.Make(missinggo.ContextedReader{R: reader, Ctx: r.Context()}, size)
+		if err != nil {
+			return fmt.Errorf("make thumbnail: %w", err)
+		}
+
+		err = app.PutThumbnail(t.InfoHash(), index, sizeName, data)
+		if err != nil {
+			log.Warn().Err(err).Msg("cache thumbnail")
+		}
+	}
+
+	setCacheHeaders(w, tag, file)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+
+	var _, err = w.Write(data)
+	if err != nil {
+		log.Warn().Err(err).Msg("write thumbnail")
+	}
+
+	return nil
+}
+
+// serveCover serves the cover art of the track or the directory,
+// cover files are preferred to the pictures embedded into tracks.
+func serveCover(w http.ResponseWriter, r *http.Request, app *app.App, t *torrent.Torrent, path string) error {
+	var cover, track = playlist.Cover(t.Files(), path)
+
+	if cover != nil {
+		return serveTorrentFile(w, r, app, t, cover.Path(), false)
+	}
+
+	if track == nil {
+		return errFileNotFound
+	}
+
+	var _, index, _ = findFile(t, track.Path())
+
+	var tag = etag(t, index, "cover")
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	var pic, err = app.Picture(r.Context(), track)
+	if err != nil {
+		return fmt.Errorf("read embedded picture: %w", err)
+	}
+
+	setCacheHeaders(w, tag, track)
+	w.Header().Set("Content-Type", pic.MIME)
+	w.Header().Set("Content-Length", strconv.Itoa(len(pic.Data)))
+
+	_, err = w.Write(pic.Data)
+	if err != nil {
+		log.Warn().Err(err).Msg("write cover")
+	}
+
+	return nil
+}
+
+// serveHLS serves the HLS playlist, the init segment or a media segment of the MP4 file.
+func serveHLS(w http.ResponseWriter, r *http.Request, app *app.App, t *torrent.Torrent, path, name string) error {
+	var file, index, ok = findFile(t, path)
+	if !ok || file == nil {
+		return errFileNotFound
+	}
+
+	var tag = etag(t, index, "hls", name)
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	var m, err = app.HLS(r.Context(), file)
+	if err != nil {
+		return fmt.Errorf("parse mp4: %w", err)
+	}
+
+	var data []byte
+	var contentType string
+
+	switch {
+	case name == "index.m3u8":
+		data = []byte(m.Playlist())
+		contentType = "application/vnd.apple.mpegurl"
+
+	case name == "init.mp4":
+		data = m.Init()
+		contentType = "video/mp4"
+
+	case strings.HasSuffix(name, ".m4s"):
+		var n, err = strconv.Atoi(strings.TrimSuffix(name, ".m4s"))
+		if err != nil {
+			return errFileNotFound
+		}
+
+		data, err = app.HLSSegment(r.Context(), file, m, n)
+		if err != nil {
+			return fmt.Errorf("read segment %d: %w", n, err)
+		}
+		contentType = "video/iso.segment"
+
+	default:
+		return errFileNotFound
+	}
+
+	// Segments are made of verified data only.
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+
+	_, err = w.Write(data)
+	if err != nil {
+		log.Warn().Err(err).Msg("write hls")
+	}
+
+	return nil
+}
+
+// serveTranscoded streams the file through the transcoding profile, the output doesn't support ranges.
+func serveTranscoded(w http.ResponseWriter, r *http.Request, app *app.App, file *torrent.File, profile *transcode.Profile, verified bool) error {
+	var reader = newFileReader(r, app, file, verified)
+	defer reader.Close()
+
+	// Read ahead 10% of file.
+	reader.SetReadahead(file.Length() * 10 / 100)
+
+	var base = filepath.Base(file.Path())
+	var name = strings.TrimSuffix(base, filepath.Ext(base)) + profile.Ext
+
+	w.Header().Set("Content-Type", profile.MIME)
+	w.Header().Set("Content-Disposition", `filename="`+url.PathEscape(name)+`"`)
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Cache-Control", "no-store")
+
+	var idle = time.Duration(*app.Settings().TranscodeIdle) * time.Second
+	var out = &startedWriter{w: w}
+
+	var err = profile.Run(r.Context(), missinggo.ContextedReader{R: reader, Ctx: r.Context()}, out, idle)
+	if err != nil && out.started {
+		// It's too late to report the error by status.
+		log.Warn().Err(err).Str("profile", profile.Name).Msg("transcode: abort streamed response")
+		abortResponse(w)
+		return nil
+	}
+
+	return err
+}
+
+// startedWriter reports whether anything is written.
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(b []byte) (int, error) {
+	s.started = s.started || len(b) > 0
+	return s.w.Write(b)
+}
+
+func findFile(t *torrent.Torrent, path string) (*torrent.File, int, bool) {
 	var file *torrent.File
+	var index int
 
 	if !t.Info().IsDir() {
 		if filepath.Base(path) != t.Info().Name {
-			return nil, false
+			return nil, 0, false
 		}
 		file = t.Files()[0]
 	} else {
-		for _, f := range t.Files() {
+		for i, f := range t.Files() {
 			var p = f.Path()
 			if p == path {
 				file = f
+				index = i
 				break
 			}
 		}
 		if file == nil {
-			return nil, false
+			return nil, 0, false
 		}
 	}
 
-	return file, true
+	return file, index, true
 }
 
-func serveContent(w http.ResponseWriter, r *http.Request, size int64, reader torrent.Reader, name string) error {
-	var err error
+// modTime returns the torrent creation date or zero time when it is unknown.
+func modTime(t *torrent.Torrent) time.Time {
+	var mi = t.Metainfo()
 
-	// Don't wait for pieces to complete and be verified.
-	reader.SetResponsive()
+	if mi.CreationDate <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(mi.CreationDate, 0).UTC()
+}
+
+// etag makes a strong entity tag of the file content.
+// Torrent data is immutable per info hash and file index.
+func etag(t *torrent.Torrent, index int, suffix ...string) string {
+	var tag = t.InfoHash().String() + "-" + strconv.Itoa(index)
+	for _, s := range suffix {
+		tag += "-" + s
+	}
+	return `"` + tag + `"`
+}
+
+// setCacheHeaders sets validators and caching policy of the file content.
+func setCacheHeaders(w http.ResponseWriter, etag string, file *torrent.File) {
+	w.Header().Set("ETag", etag)
+
+	if file.BytesCompleted() == file.Length() {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		// Force revalidation until the file is downloaded completely.
+		w.Header().Set("Cache-Control", "public, no-cache")
+	}
+}
+
+func serveContent(w http.ResponseWriter, r *http.Request, size int64, reader torrent.Reader, name string, modtime time.Time) error {
+	var err error
 
 	if size > 0 {
 		// Read ahead 10% of file.
@@ -167,6 +623,89 @@ func serveContent(w http.ResponseWriter, r *http.Request, size int64, reader tor
 		return err
 	}
 
-	http.ServeContent(w, r, name, time.Now(), reader)
+	setContentType(w, name)
+
+	// Handles If-None-Match, If-Modified-Since and If-Range using headers set before.
+	http.ServeContent(w, r, name, modtime, reader)
 	return nil
 }
+
+// setContentType sets content type by the file extension, so the content isn't sniffed by reading it.
+func setContentType(w http.ResponseWriter, name string) {
+	var ctype = mime.TypeByExtension(filepath.Ext(name))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", ctype)
+}
+
+// abortResponse breaks the connection, so the client doesn't take the truncated response as complete.
+func abortResponse(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		var conn, _, err = hj.Hijack()
+		if err == nil {
+			conn.Close()
+			return
+		}
+	}
+
+	// HTTP/2 stream is reset by the server when the panic isn't recovered.
+	panic(http.ErrAbortHandler)
+}
+
+// notVerified reports whether the reader gave up on a piece not verified within the timeout.
+func notVerified(reader torrent.Reader) bool {
+	var v, ok = reader.(*verifiedReader)
+	return ok && v.failed
+}
+
+// verifiedReader reads only hash checked data, it fails with errNotVerified
+// when a piece is not verified within the timeout.
+type verifiedReader struct {
+	torrent.Reader
+
+	ctx     context.Context
+	file    *torrent.File
+	pos     int64
+	timeout time.Duration
+	failed  bool
+}
+
+func (v *verifiedReader) Read(b []byte) (int, error) {
+	return v.ReadContext(v.ctx, b)
+}
+
+func (v *verifiedReader) ReadContext(ctx context.Context, b []byte) (int, error) {
+	var readCtx, cancel = context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	var n, err = v.Reader.ReadContext(readCtx, b)
+	if n == 0 && ctx.Err() == nil && readCtx.Err() == context.DeadlineExceeded {
+		var t = v.file.Torrent()
+		var piece = int((v.file.Offset() + v.pos) / t.Info().PieceLength)
+
+		log.Warn().
+			Str("hash", t.InfoHash().String()).
+			Str("path", v.file.Path()).
+			Int("piece", piece).
+			Interface("state", t.PieceState(piece)).
+			Msgf("abort verified transfer: piece is not verified within %s", v.timeout)
+
+		v.failed = true
+
+		return 0, errNotVerified
+	}
+
+	v.pos += int64(n)
+
+	return n, err
+}
+
+func (v *verifiedReader) Seek(offset int64, whence int) (int64, error) {
+	var pos, err = v.Reader.Seek(offset, whence)
+	if err == nil {
+		v.pos = pos
+	}
+	return pos, err
+}
@@ -2,7 +2,11 @@ package host
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 type contextKey int
@@ -11,19 +15,201 @@ const (
 	ContextKeyHost contextKey = iota
 )
 
+var (
+	// Proxies trusted to set forwarding headers.
+	trusted []*net.IPNet
+	// Base URL of the service, nil by default.
+	base *url.URL
+)
+
+// SetTrustedProxies sets IP addresses and CIDR networks of proxies trusted to set forwarding headers.
+// It must be called before serving requests.
+func SetTrustedProxies(proxies []string) error {
+	var nets = make([]*net.IPNet, 0, len(proxies))
+
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			var ip = net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("parse proxy ip %q", p)
+			}
+
+			var bits = 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		var _, n, err = net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("parse proxy network: %w", err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	trusted = nets
+
+	return nil
+}
+
+// SetBaseURL sets the base URL of the service: absolute like "https://example.com/torrents"
+// overrides the request origin, path like "/torrents" is only the path prefix of the routes.
+// It must be called before serving requests.
+func SetBaseURL(s string) error {
+	if s == "" {
+		base = nil
+		return nil
+	}
+
+	var u, err = url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("parse base url: %w", err)
+	}
+
+	if u.Host != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("base url scheme %q is not supported", u.Scheme)
+	}
+
+	if u.Host == "" && u.Scheme != "" {
+		return fmt.Errorf("base url %q has no host", s)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("base url %q has query or fragment", s)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if u.Path != "" && !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+
+	base = u
+
+	return nil
+}
+
+// Prefix returns the path prefix of the routes without trailing slash, empty for the root.
+func Prefix() string {
+	if base == nil {
+		return ""
+	}
+	return base.Path
+}
+
 func Host(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var scheme string
 
 		if r.TLS != nil {
-			scheme = "https://"
+			scheme = "https"
 		} else {
-			scheme = "http://"
+			scheme = "http"
 		}
 
-		var host = scheme + r.Host
+		var host = r.Host
 
-		var ctx = context.WithValue(r.Context(), ContextKeyHost, host)
+		if isTrusted(r.RemoteAddr) {
+			scheme, host = forwarded(r, scheme, host)
+		}
+
+		if base != nil && base.Host != "" {
+			scheme, host = base.Scheme, base.Host
+		}
+
+		var origin = scheme + "://" + host + Prefix()
+
+		var ctx = context.WithValue(r.Context(), ContextKeyHost, origin)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func isTrusted(remoteAddr string) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+
+	var addr, _, err = net.SplitHostPort(remoteAddr)
+	if err != nil {
+		addr = remoteAddr
+	}
+
+	var ip = net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwarded returns scheme and host of the client request taken from Forwarded header (RFC 7239)
+// or X-Forwarded-Proto and X-Forwarded-Host headers. Values appended by the trusted proxy are used,
+// the proxy appends its values to the ones sent by the client, so only the rightmost values are reliable.
+func forwarded(r *http.Request, scheme, host string) (string, string) {
+	var proto, fwdHost string
+
+	if v := lastValue(r.Header.Values("Forwarded")); v != "" {
+		for _, pair := range strings.Split(v, ";") {
+			var key, value, _ = strings.Cut(strings.TrimSpace(pair), "=")
+			value = strings.Trim(value, `"`)
+
+			switch strings.ToLower(key) {
+			case "proto":
+				proto = value
+			case "host":
+				fwdHost = value
+			}
+		}
+	} else {
+		proto = lastValue(r.Header.Values("X-Forwarded-Proto"))
+		fwdHost = lastValue(r.Header.Values("X-Forwarded-Host"))
+	}
+
+	if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	if validHost(fwdHost) {
+		host = fwdHost
+	}
+
+	return scheme, host
+}
+
+// lastValue returns the last element of the comma separated list spread over the header lines.
+func lastValue(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var v = lines[len(lines)-1]
+	if i := strings.LastIndexByte(v, ','); i >= 0 {
+		v = v[i+1:]
+	}
+
+	return strings.TrimSpace(v)
+}
+
+// validHost reports whether the value is a host with optional port fitting into URL.
+func validHost(host string) bool {
+	if host == "" {
+		return false
+	}
+
+	var u, err = url.Parse("http://" + host)
+	return err == nil && u.Host == host && u.Path == "" && u.User == nil
+}
@@ -0,0 +1,147 @@
+package host
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	var tests = []struct {
+		name    string
+		trusted []string
+		base    string
+		remote  string
+		header  http.Header
+		want    string
+	}{
+		{
+			name:   "request host",
+			remote: "10.0.0.1:1234",
+			want:   "http://example.com",
+		},
+		{
+			name:   "untrusted x-forwarded headers are ignored",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.com"}},
+			want:   "http://example.com",
+		},
+		{
+			name:    "untrusted forwarded header is ignored",
+			trusted: []string{"10.0.0.2"},
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"Forwarded": {"proto=https;host=evil.com"}},
+			want:    "http://example.com",
+		},
+		{
+			name:    "trusted ip",
+			trusted: []string{"10.0.0.1"},
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"proxy.com"}},
+			want:    "https://proxy.com",
+		},
+		{
+			name:    "trusted network",
+			trusted: []string{"192.168.0.0/16", "fd00::/8"},
+			remote:  "[fd00::1]:1234",
+			header:  http.Header{"Forwarded": {`proto=https;host="proxy.com:8443"`}},
+			want:    "https://proxy.com:8443",
+		},
+		{
+			name:    "rightmost x-forwarded values win",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-Proto": {"http, https"},
+				"X-Forwarded-Host":  {"evil.com, evil.org", "proxy.com"},
+			},
+			want: "https://proxy.com",
+		},
+		{
+			name:    "rightmost forwarded element wins",
+			trusted: []string{"10.0.0.1"},
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"Forwarded": {"host=evil.com;proto=http, host=proxy.com;proto=https"}},
+			want:    "https://proxy.com",
+		},
+		{
+			name:    "invalid forwarded host is rejected",
+			trusted: []string{"10.0.0.1"},
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"X-Forwarded-Host": {"evil.com/path"}, "X-Forwarded-Proto": {"ftp"}},
+			want:    "http://example.com",
+		},
+		{
+			name:    "forwarded host with user info is rejected",
+			trusted: []string{"10.0.0.1"},
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"Forwarded": {"host=user@evil.com"}},
+			want:    "http://example.com",
+		},
+		{
+			name:    "absolute base url overrides origin",
+			trusted: []string{"10.0.0.1"},
+			base:    "https://public.com/torrents/",
+			remote:  "10.0.0.1:1234",
+			header:  http.Header{"X-Forwarded-Host": {"proxy.com"}},
+			want:    "https://public.com/torrents",
+		},
+		{
+			name:   "path base url only adds prefix",
+			base:   "torrents",
+			remote: "10.0.0.1:1234",
+			want:   "http://example.com/torrents",
+		},
+	}
+
+	defer func() {
+		_ = SetTrustedProxies(nil)
+		_ = SetBaseURL("")
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetBaseURL(tt.base); err != nil {
+				t.Fatal(err)
+			}
+
+			var r = httptest.NewRequest(http.MethodGet, "http://example.com/list", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			var got string
+			Host(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = r.Context().Value(ContextKeyHost).(string)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	defer func() { _ = SetTrustedProxies(nil) }()
+
+	for _, p := range []string{"10.0.0", "10.0.0.0/33", "proxy.com"} {
+		if err := SetTrustedProxies([]string{p}); err == nil {
+			t.Errorf("SetTrustedProxies(%q) succeeded, want error", p)
+		}
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	defer func() { _ = SetBaseURL("") }()
+
+	for _, s := range []string{"ftp://example.com", "https:", "/torrents?a=1", "/torrents#top"} {
+		if err := SetBaseURL(s); err == nil {
+			t.Errorf("SetBaseURL(%q) succeeded, want error", s)
+		}
+	}
+}
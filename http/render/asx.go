@@ -0,0 +1,80 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+type asxPlaylist struct {
+	XMLName xml.Name   `xml:"asx"`
+	Version string     `xml:"version,attr"`
+	Title   string     `xml:"title"`
+	Entries []asxEntry `xml:"entry"`
+}
+
+type asxEntry struct {
+	Title  string    `xml:"title"`
+	Author string    `xml:"author,omitempty"`
+	Ref    asxValue  `xml:"ref"`
+	Start  *asxValue `xml:"starttime,omitempty"`
+	// Duration of cue sheet tracks.
+	Duration *asxValue `xml:"duration,omitempty"`
+}
+
+type asxValue struct {
+	Href  string `xml:"href,attr,omitempty"`
+	Value string `xml:"value,attr,omitempty"`
+}
+
+func ASX(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var doc = asxPlaylist{
+		Version: "3.0",
+		Title:   list.Header.Name,
+		Entries: make([]asxEntry, 0, len(list.Content)),
+	}
+
+	for _, itm := range list.Content {
+		var entry = asxEntry{
+			Title:  itm.Name,
+			Author: itm.Artist,
+			Ref:    asxValue{Href: itm.URL},
+		}
+
+		if itm.Start > 0 {
+			entry.Start = &asxValue{Value: asxTime(itm.Start)}
+		}
+		if itm.End > 0 {
+			entry.Duration = &asxValue{Value: asxTime(itm.End - itm.Start)}
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".asx"))
+	w.Header().Set("Content-Type", "video/x-ms-asf; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	var enc = xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	var err = enc.Encode(doc)
+	if err != nil {
+		log.Error().Err(err).Msg("responder asx")
+		return
+	}
+}
+
+// asxTime formats seconds as hh:mm:ss.fff.
+func asxTime(seconds float64) string {
+	var ms = int64(seconds*1000 + 0.5)
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
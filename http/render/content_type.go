@@ -2,55 +2,227 @@ package render
 
 import (
 	"context"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
 )
 
 const ParamContentType = "ContentType"
 
-// ContentType is an enumeration of common HTTP content types.
-type ContentType int
+// FormatJSON is the default list format.
+const FormatJSON = "json"
+
+type contextKey int
 
-// ContentTypes handled by this package.
 const (
-	ContentTypeUnknown = iota
-	ContentTypeHTML
-	ContentTypeJSON
-	ContentTypeM3U
+	ContextKeyFormat contextKey = iota
+	ContextKeyPageURL
 )
 
-func ContentTypeFromString(s string) ContentType {
-	switch s {
-	case "html":
-		return ContentTypeHTML
-	case "json":
-		return ContentTypeJSON
-	case "m3u":
-		return ContentTypeM3U
-	default:
-		return ContentTypeUnknown
+// Renderer writes the list in the output format.
+type Renderer interface {
+	Render(w http.ResponseWriter, r *http.Request, list *playlist.PlayList)
+}
+
+// RendererFunc is an adapter to use ordinary functions as Renderer.
+type RendererFunc func(w http.ResponseWriter, r *http.Request, list *playlist.PlayList)
+
+func (f RendererFunc) Render(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	f(w, r, list)
+}
+
+// Format is the list output format.
+type Format struct {
+	// Name is used by list routes, e.g. "m3u".
+	Name string
+	// Media types of Accept header selecting the format.
+	MIME []string
+
+	Renderer Renderer
+}
+
+var formats = struct {
+	sync.RWMutex
+
+	byName map[string]Format
+	byMIME map[string]Format
+}{
+	byName: map[string]Format{},
+	byMIME: map[string]Format{},
+}
+
+func init() {
+	Register(Format{Name: FormatJSON, MIME: []string{"application/json"}, Renderer: RendererFunc(JSON)})
+	Register(Format{Name: "html", MIME: []string{"text/html", "application/xhtml+xml"}, Renderer: RendererFunc(HTML)})
+	Register(Format{
+		Name:     "m3u",
+		MIME:     []string{"audio/x-mpegurl", "audio/mpegurl", "application/x-mpegurl", "application/vnd.apple.mpegurl"},
+		Renderer: RendererFunc(M3U),
+	})
+	Register(Format{Name: "iptv", Renderer: RendererFunc(IPTV)})
+	Register(Format{Name: "xspf", MIME: []string{"application/xspf+xml"}, Renderer: RendererFunc(XSPF)})
+	Register(Format{Name: "pls", MIME: []string{"audio/x-scpls"}, Renderer: RendererFunc(PLS)})
+	Register(Format{Name: "asx", MIME: []string{"video/x-ms-asf", "video/x-ms-asx"}, Renderer: RendererFunc(ASX)})
+	Register(Format{Name: "wpl", MIME: []string{"application/vnd.ms-wpl"}, Renderer: RendererFunc(WPL)})
+}
+
+// Register adds the list format, a format of the same name or media type is replaced.
+// It's safe to register formats while serving requests.
+func Register(f Format) {
+	if f.Name == "" || f.Renderer == nil {
+		panic("render: format name and renderer are required")
 	}
+
+	formats.Lock()
+	defer formats.Unlock()
+
+	formats.byName[strings.ToLower(f.Name)] = f
+
+	for _, t := range f.MIME {
+		formats.byMIME[strings.ToLower(t)] = f
+	}
+}
+
+// Lookup returns the registered format by name.
+func Lookup(name string) (Format, bool) {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	var f, ok = formats.byName[strings.ToLower(name)]
+	return f, ok
 }
 
-func GetAcceptedContentType(r *http.Request) ContentType {
-	if contentType, ok := r.Context().Value(render.ContentTypeCtxKey).(ContentType); ok {
-		return contentType
+// Negotiate returns the format by Accept header value.
+// The most preferred registered media type wins, JSON is the default.
+func Negotiate(accept string) Format {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	var format = formats.byName[FormatJSON]
+	var best float64
+
+	for _, part := range strings.Split(accept, ",") {
+		var mediaType, params, err = mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var q = 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		var f, ok = formats.byMIME[mediaType]
+		if ok && q > best {
+			format, best = f, q
+		}
 	}
 
-	var p = chi.URLParam(r, ParamContentType)
-	var contentType = ContentTypeFromString(p)
+	return format
+}
 
-	if contentType == ContentTypeUnknown {
-		contentType = ContentTypeJSON
+// WithFormat returns the context carrying the list format of the request.
+func WithFormat(ctx context.Context, f Format) context.Context {
+	return context.WithValue(ctx, ContextKeyFormat, f)
+}
+
+// FormatFromContext returns the list format of the request, JSON is the default.
+func FormatFromContext(ctx context.Context) Format {
+	if f, ok := ctx.Value(ContextKeyFormat).(Format); ok {
+		return f
 	}
-	return contentType
+
+	var f, _ = Lookup(FormatJSON)
+	return f
 }
 
-func ListContentType(next http.Handler) http.Handler {
+// ListFormat takes the list format from the URL parameter.
+func ListFormat(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var ctx = context.WithValue(r.Context(), render.ContentTypeCtxKey, GetAcceptedContentType(r))
-		next.ServeHTTP(w, r.WithContext(ctx))
+		var f, ok = Lookup(chi.URLParam(r, ParamContentType))
+		if !ok {
+			http.Error(w, "unknown format", http.StatusNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithFormat(r.Context(), f)))
 	})
 }
+
+// JSON writes the list as JSON.
+func JSON(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	render.JSON(w, r, list)
+}
+
+// List builds the list and writes it in the format of the request.
+func List(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var err = list.Render(w, r)
+	if err != nil {
+		log.Error().Err(err).Msg("render list")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	FormatFromContext(r.Context()).Renderer.Render(w, r, list)
+}
+
+// attachment returns Content-Disposition value of the file download.
+// Non-ASCII file names are encoded by RFC 2231 following an ASCII fallback for legacy clients.
+func attachment(name string) string {
+	return disposition("attachment", name)
+}
+
+// inline returns Content-Disposition value of the file displayed by the browser.
+func inline(name string) string {
+	return disposition("inline", name)
+}
+
+func disposition(typ, name string) string {
+	var fallback = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	var value = typ + `; filename="` + fallback + `"`
+
+	if fallback != name {
+		value += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+
+	return value
+}
+
+// encodeExtValue percent-encodes the value except of RFC 5987 attr-chars.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("!#$&+-.^_`|~", c) >= 0:
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0x0f])
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,139 @@
+package render
+
+import (
+	"encoding/xml"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/http/host"
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate,omitempty"`
+	Image       *rssImage `xml:"itunes:image,omitempty"`
+	Type        string    `xml:"itunes:type"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	Author    string       `xml:"itunes:author,omitempty"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+	GUID      rssGUID      `xml:"guid"`
+	PubDate   string       `xml:"pubDate"`
+	Duration  int64        `xml:"itunes:duration,omitempty"`
+	Episode   int          `xml:"itunes:episode"`
+	Image     *rssImage    `xml:"itunes:image,omitempty"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Feed renders audio and video items of the playlist as RSS 2.0 podcast feed in natural path order.
+// Publication dates follow the torrent creation date by a minute, so podcast apps keep the order.
+func Feed(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+
+	var created time.Time
+	if date := list.Torr.Metainfo().CreationDate; date > 0 {
+		created = time.Unix(date, 0).UTC()
+	} else {
+		created = time.Unix(0, 0).UTC()
+	}
+
+	var items = make([]playlist.Item, 0, len(list.Content))
+	for _, itm := range list.Content {
+		if playlist.IsAudio(itm.MIME) || playlist.IsVideo(itm.MIME) {
+			items = append(items, itm)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return playlist.NaturalLess(strings.Join(items[i].Path, "/"), strings.Join(items[j].Path, "/"))
+	})
+
+	var channel = rssChannel{
+		Title:       list.Header.Name,
+		Link:        origin + "/list/html/-/-/hash/" + list.Header.Hash,
+		Description: list.Header.Name,
+		PubDate:     created.Format(time.RFC1123Z),
+		Type:        "serial",
+		Items:       make([]rssItem, 0, len(items)),
+	}
+
+	for i, itm := range items {
+		var item = rssItem{
+			Title:     itm.Name,
+			Author:    itm.Artist,
+			Enclosure: rssEnclosure{URL: itm.URL, Length: itm.Size, Type: itm.MIME},
+			GUID:      rssGUID{Value: list.Header.Hash + "/" + list.ItemPath(itm.Path)},
+			PubDate:   created.Add(time.Duration(i) * time.Minute).Format(time.RFC1123Z),
+			Duration:  int64(math.Round(itm.Duration)),
+			Episode:   i + 1,
+		}
+
+		// Cue sheet tracks share the file.
+		if itm.Start > 0 || itm.End > 0 {
+			item.GUID.Value += "#t=" + strconv.FormatFloat(itm.Start, 'f', 3, 64)
+		}
+
+		if itm.Cover != "" {
+			item.Image = &rssImage{Href: itm.Cover}
+			if channel.Image == nil {
+				channel.Image = &rssImage{Href: itm.Cover}
+			}
+		}
+
+		channel.Items = append(channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+
+	var _, err = w.Write([]byte(xml.Header))
+	if err != nil {
+		log.Error().Err(err).Msg("feed header")
+		return
+	}
+
+	var enc = xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = enc.Encode(rss{
+		Version: "2.0",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: channel,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("feed")
+		return
+	}
+}
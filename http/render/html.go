@@ -0,0 +1,167 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/http/host"
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+// ListPage is the data of the HTML list template.
+type ListPage struct {
+	Title  string
+	Header playlist.Header
+	// Player URL of all audio items, empty when there are no audio items.
+	PlayAll string
+	// Image items of photo collections rendered as gallery of thumbnails.
+	Gallery []playlist.Item
+	// The rest items rendered as links.
+	Items []playlist.Item
+	// URLs of the previous and the next pages of the paged list.
+	Prev string
+	Next string
+}
+
+//go:embed templates/list.html
+var listTemplateText string
+
+var templateFuncs = template.FuncMap{
+	"path": func(path []string) string {
+		return strings.Join(path, "/")
+	},
+}
+
+var listTemplate = template.Must(template.New("list.html").Funcs(templateFuncs).Parse(listTemplateText))
+
+// LoadTemplates parses html templates of the directory over the embedded ones.
+// File list.html replaces the list page, other files may redefine its "style", "header" and "footer" blocks.
+// It must be called before serving requests.
+func LoadTemplates(dir string) error {
+	var t, err = template.New("list.html").Funcs(templateFuncs).Parse(listTemplateText)
+	if err != nil {
+		return fmt.Errorf("parse list template: %w", err)
+	}
+
+	t, err = t.ParseGlob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return fmt.Errorf("parse templates: %w", err)
+	}
+
+	listTemplate = t
+
+	return nil
+}
+
+func HTML(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var page = ListPage{
+		Title:  list.Header.Name,
+		Header: list.Header,
+	}
+
+	for _, itm := range list.Content {
+		// Player pages don't support merged lists.
+		if playlist.IsAudio(itm.MIME) && list.Header.Hash != "" {
+			var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+			page.PlayAll = origin + "/player/" + list.Header.Hash + "/" + list.FilterQuery()
+			break
+		}
+	}
+
+	var images int
+	for _, itm := range list.Content {
+		if itm.Thumbnail != "" {
+			images++
+		}
+	}
+
+	// Photo collections are rendered as gallery of thumbnails followed by the rest links.
+	var gallery = images*2 > len(list.Content)
+
+	for _, itm := range list.Content {
+		if gallery && itm.Thumbnail != "" {
+			page.Gallery = append(page.Gallery, itm)
+		} else {
+			page.Items = append(page.Items, itm)
+		}
+	}
+
+	page.Prev, page.Next = pageLinks(r, list)
+
+	// Template errors are reported before the response is started.
+	var buf bytes.Buffer
+
+	var err = listTemplate.Execute(&buf, page)
+	if err != nil {
+		log.Error().Err(err).Msg("responder html")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", inline(list.Header.Name+".html"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		log.Error().Err(err).Msg("responder html: write")
+		return
+	}
+}
+
+// WithPageURL returns the context carrying the URL of the list pages,
+// it is used when the request parameters are not in the query, e.g. in the body.
+func WithPageURL(ctx context.Context, page string) context.Context {
+	return context.WithValue(ctx, ContextKeyPageURL, page)
+}
+
+// pageLinks returns URLs of the previous and the next pages of the paged list.
+func pageLinks(r *http.Request, list *playlist.PlayList) (prev, next string) {
+	var limit = list.Order.Limit
+	if limit <= 0 {
+		return "", ""
+	}
+
+	var base string
+	var q = r.URL.Query()
+
+	if page, ok := r.Context().Value(ContextKeyPageURL).(string); ok {
+		var query string
+		base, query, _ = strings.Cut(page, "?")
+		q, _ = url.ParseQuery(query)
+	}
+
+	if offset := list.Header.Offset; offset > 0 {
+		var n = offset - limit
+		if n < 0 {
+			n = 0
+		}
+
+		q.Del("cursor")
+		q.Set("offset", strconv.Itoa(n))
+
+		prev = base + "?" + q.Encode()
+	}
+
+	if list.Header.Next != "" {
+		q.Del("offset")
+		q.Set("cursor", list.Header.Next)
+
+		next = base + "?" + q.Encode()
+	}
+
+	return prev, next
+}
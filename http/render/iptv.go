@@ -0,0 +1,116 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/archive"
+	"github.com/WinPooh32/peerstohttp/playlist"
+	"github.com/WinPooh32/peerstohttp/playlist/name"
+)
+
+// IPTV writes video items as channels of IPTV playlist grouped by series and seasons.
+func IPTV(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var err error
+	var buf = bufio.NewWriter(w)
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".m3u"))
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	_, err = buf.WriteString("#EXTM3U\r\n")
+	if err != nil {
+		log.Error().Err(err).Msg("responder iptv header")
+		return
+	}
+
+	// Numbers continue previous pages, so channels of every page are told apart.
+	var channel = list.Header.Offset
+
+	for _, itm := range list.Content {
+		if !playlist.IsVideo(itm.MIME) {
+			continue
+		}
+
+		channel++
+
+		var info = name.Video(strings.TrimSuffix(itm.NameOrig, itm.Ext)).Parse()
+
+		var title = itm.Name
+		var group = itemGroup(list, itm)
+
+		if info.Title != "" {
+			title = info.Title
+			group = info.Title
+		}
+
+		if info.Season > 0 {
+			group += fmt.Sprintf(" - Season %d", info.Season)
+		}
+		if info.Episode > 0 {
+			title += fmt.Sprintf(" S%02dE%02d", info.Season, info.Episode)
+		}
+
+		var logo = itm.Cover
+		if logo == "" {
+			logo = itm.Thumbnail
+		}
+
+		_, err = buf.WriteString(
+			"#EXTINF:-1" +
+				m3uAttr("tvg-id", itm.Source+"/"+strconv.Itoa(channel)) +
+				m3uAttr("tvg-chno", strconv.Itoa(channel)) +
+				m3uAttr("tvg-name", title) +
+				m3uAttr("tvg-logo", logo) +
+				m3uAttr("group-title", group) +
+				"," + m3uText(title) + "\r\n" +
+				itm.URL + "\r\n",
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("responder iptv item")
+			return
+		}
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		log.Error().Err(err).Msg("responder iptv: flush buffer")
+		return
+	}
+}
+
+// itemGroup returns the parent directory name of the item or the name of its torrent.
+func itemGroup(list *playlist.PlayList, itm playlist.Item) string {
+	if n := len(itm.Path); n > 1 && itm.Path[n-2] != archive.Separator {
+		return itm.Path[n-2]
+	}
+
+	for _, src := range list.Header.Sources {
+		if src.Hash == itm.Source {
+			return src.Name
+		}
+	}
+
+	return list.Header.Name
+}
+
+// m3uText makes the value fit into a single line of the playlist.
+func m3uText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// m3uAttr returns the attribute of #EXTINF line, empty values are omitted.
+func m3uAttr(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return " " + key + `="` + strings.ReplaceAll(m3uText(value), `"`, "'") + `"`
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
+	name_reader "github.com/WinPooh32/peerstohttp/playlist/name"
+)
+
+type kodiMovie struct {
+	XMLName xml.Name `xml:"movie"`
+	Title   string   `xml:"title"`
+	Year    int      `xml:"year,omitempty"`
+}
+
+type kodiShow struct {
+	XMLName xml.Name `xml:"tvshow"`
+	Title   string   `xml:"title"`
+}
+
+type kodiEpisode struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle"`
+	Season    int      `xml:"season"`
+	Episode   int      `xml:"episode"`
+}
+
+// kodiFile is a file of the exported library.
+type kodiFile struct {
+	path string
+	data []byte
+}
+
+// Kodi renders zip of .strm files referring content URLs of audio and video items
+// with .nfo stubs of movies and episodes parsed from file names.
+func Kodi(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var files []kodiFile
+	var taken = map[string]struct{}{}
+
+	// unique returns the free path adding a counter to the name.
+	var unique = func(dir, name string) string {
+		var p = path.Join(dir, name)
+		for i := 2; ; i++ {
+			if _, ok := taken[p]; !ok {
+				break
+			}
+			p = path.Join(dir, name+" ("+strconv.Itoa(i)+")")
+		}
+		taken[p] = struct{}{}
+		return p
+	}
+
+	var shows = map[string]struct{}{}
+	var cueFiles = map[string]struct{}{}
+
+	for _, itm := range list.Content {
+		var strm = []byte(itm.URL + "\n")
+
+		switch {
+		case playlist.IsVideo(itm.MIME):
+			var base = strings.TrimSuffix(itm.NameOrig, itm.Ext)
+			var info = name_reader.Video(base).Parse()
+
+			var dir, name string
+			var nfo interface{}
+
+			if info.Episode > 0 {
+				var show = fileName(info.Title)
+
+				dir = path.Join(show, "Season "+strconv.Itoa(info.Season))
+				name = fmt.Sprintf("%s S%02dE%02d", show, info.Season, info.Episode)
+				nfo = kodiEpisode{Title: name, ShowTitle: info.Title, Season: info.Season, Episode: info.Episode}
+
+				if _, ok := shows[show]; !ok {
+					shows[show] = struct{}{}
+					files = append(files, kodiFile{path.Join(show, "tvshow.nfo"), nfoData(kodiShow{Title: info.Title})})
+				}
+			} else {
+				name = fileName(info.Title)
+				if info.Year > 0 {
+					name += " (" + strconv.Itoa(info.Year) + ")"
+				}
+
+				dir = name
+				nfo = kodiMovie{Title: info.Title, Year: info.Year}
+			}
+
+			var p = unique(dir, name)
+
+			files = append(files,
+				kodiFile{p + ".strm", strm},
+				kodiFile{p + ".nfo", nfoData(nfo)},
+			)
+
+		case playlist.IsAudio(itm.MIME):
+			// Music keeps the torrent tree.
+			var dir = make([]string, 0, len(itm.Path))
+			for _, s := range itm.Path[:len(itm.Path)-1] {
+				dir = append(dir, fileName(s))
+			}
+
+			// Stream files can't refer offsets, tracks of cue sheets are exported as the whole file once.
+			if itm.Start > 0 || itm.End > 0 {
+				if _, ok := cueFiles[itm.URL]; ok {
+					continue
+				}
+				cueFiles[itm.URL] = struct{}{}
+			}
+
+			var name = fileName(strings.TrimSuffix(itm.NameOrig, itm.Ext))
+
+			files = append(files, kodiFile{unique(path.Join(fileName(list.Header.Name), path.Join(dir...)), name) + ".strm", strm})
+		}
+	}
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".zip"))
+	w.Header().Set("Content-Type", "application/zip")
+
+	var zw = zip.NewWriter(w)
+
+	for _, f := range files {
+		var fw, err = zw.Create(f.path)
+		if err != nil {
+			log.Error().Err(err).Msg("kodi export entry")
+			return
+		}
+
+		_, err = fw.Write(f.data)
+		if err != nil {
+			log.Error().Err(err).Msg("kodi export entry")
+			return
+		}
+	}
+
+	var err = zw.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("kodi export")
+		return
+	}
+}
+
+func nfoData(v interface{}) []byte {
+	var data, err = xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("kodi nfo")
+		return nil
+	}
+	return append([]byte(xml.Header), data...)
+}
+
+// fileName replaces characters not allowed in file names of common file systems.
+func fileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+
+	s = strings.Trim(s, " .")
+	if s == "" {
+		return "_"
+	}
+
+	return s
+}
@@ -0,0 +1,210 @@
+package render
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/http/host"
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+// PlayerTrack is a playable item of the player page.
+type PlayerTrack struct {
+	playlist.Item
+
+	Stats string
+}
+
+// PlayerPage is the data of the player page.
+type PlayerPage struct {
+	Title string
+	// Tag of the media element: audio or video.
+	Kind    string
+	Current int
+	Tracks  []PlayerTrack
+	// All tracks are played in place.
+	Playlist bool
+	// Player URLs of the previous and the next items.
+	Prev string
+	Next string
+}
+
+var playerTemplate = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.Title}}</title>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+<meta name="viewport" content="width=device-width, initial-scale=1" />
+<style>
+body { font-family: sans-serif; margin: 1em; }
+video, audio { width: 100%; max-height: 80vh; }
+nav, #status { margin: .5em 0; }
+progress { width: 100%; }
+ol li.current { font-weight: bold; }
+</style>
+</head>
+
+<body>
+<h3 id="title">{{with index .Tracks .Current}}{{.Name}}{{end}}</h3>
+
+{{with index .Tracks .Current}}
+{{if eq $.Kind "video"}}
+<video id="media" controls autoplay preload="auto" src="{{.URL}}">
+{{range $i, $sub := .Subtitles}}<track kind="subtitles" src="{{$sub.URL}}" srclang="{{$sub.Lang}}" label="{{if $sub.Lang}}{{$sub.Lang}}{{else}}{{$sub.Name}}{{end}}"{{if eq $i 0}} default{{end}}>
+{{end}}</video>
+{{else}}
+<audio id="media" controls autoplay preload="auto" src="{{.URL}}"></audio>
+{{end}}
+{{end}}
+
+<div id="status">
+<progress id="progress" max="100" value="0"></progress>
+<span id="buffering" hidden>Buffering&hellip;</span>
+<span id="stats"></span>
+</div>
+
+<nav>
+{{if .Prev}}<a id="prev" href="{{.Prev}}">&larr; Previous</a>{{end}}
+{{if .Next}}<a id="next" href="{{.Next}}">Next &rarr;</a>{{end}}
+</nav>
+
+{{if gt (len .Tracks) 1}}
+<ol id="tracks">
+{{range $i, $t := .Tracks}}<li{{if eq $i $.Current}} class="current"{{end}}><a href="{{$t.Player}}" data-index="{{$i}}">{{$t.Name}}</a></li>
+{{end}}</ol>
+{{end}}
+
+<script>
+(function() {
+	var tracks = [{{range .Tracks}}{url: {{.URL}}, stats: {{.Stats}}, name: {{.Name}}},{{end}}];
+	var current = {{.Current}};
+	// Audio playlist plays tracks in place, otherwise navigation follows links.
+	var inplace = {{.Playlist}} && tracks.length > 1;
+
+	var media = document.getElementById("media");
+	var buffering = document.getElementById("buffering");
+	var progress = document.getElementById("progress");
+	var stats = document.getElementById("stats");
+	var last = null;
+
+	function play(i) {
+		var items = document.querySelectorAll("#tracks li");
+		items[current].className = "";
+		current = i;
+		items[current].className = "current";
+		document.getElementById("title").textContent = tracks[i].name;
+		media.src = tracks[i].url;
+		media.play();
+		last = null;
+	}
+
+	if (inplace) {
+		document.querySelectorAll("#tracks a").forEach(function(a) {
+			a.addEventListener("click", function(e) {
+				e.preventDefault();
+				play(parseInt(a.dataset.index));
+			});
+		});
+	}
+
+	media.addEventListener("waiting", function() { buffering.hidden = false; });
+	media.addEventListener("playing", function() { buffering.hidden = true; });
+	media.addEventListener("ended", function() {
+		if (inplace) {
+			if (current + 1 < tracks.length) {
+				play(current + 1);
+			}
+		} else {
+			var next = document.getElementById("next");
+			if (next) {
+				window.location = next.href;
+			}
+		}
+	});
+
+	function poll() {
+		fetch(tracks[current].stats).then(function(r) { return r.json(); }).then(function(s) {
+			var speed = "";
+			var now = Date.now();
+			if (last) {
+				speed = ", " + Math.round((s.bytes_read - last.bytes_read) / 1024 / ((now - last.time) / 1000)) + " KiB/s";
+			}
+			last = {bytes_read: s.bytes_read, time: now};
+
+			progress.value = s.percent;
+			stats.textContent = s.percent.toFixed(1) + "% downloaded, " + s.peers + " peers, " + s.seeders + " seeders" + speed;
+		}).catch(function() {});
+	}
+
+	poll();
+	setInterval(poll, 2000);
+})();
+</script>
+</body>
+</html>
+`))
+
+// Player renders the HTML5 player page of the playlist item at the path,
+// the empty path plays all audio items of the playlist.
+func Player(w http.ResponseWriter, r *http.Request, list *playlist.PlayList, path string) {
+	var origin, _ = r.Context().Value(host.ContextKeyHost).(string)
+
+	var page = PlayerPage{
+		Title:    list.Header.Name,
+		Kind:     "audio",
+		Playlist: path == "",
+	}
+	var found = page.Playlist
+
+	for _, itm := range list.Content {
+		if path == "" && !playlist.IsAudio(itm.MIME) {
+			continue
+		}
+		if itm.Player == "" {
+			continue
+		}
+
+		if !page.Playlist && list.ItemPath(itm.Path) == path {
+			found = true
+			page.Current = len(page.Tracks)
+			page.Title = itm.Name
+
+			if playlist.IsVideo(itm.MIME) {
+				page.Kind = "video"
+			}
+		}
+
+		page.Tracks = append(page.Tracks, PlayerTrack{
+			Item:  itm,
+			Stats: origin + "/stats/" + list.ContentPath(itm.Path),
+		})
+	}
+
+	if !found || len(page.Tracks) == 0 {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if !page.Playlist {
+		if page.Current > 0 {
+			page.Prev = page.Tracks[page.Current-1].Player
+		}
+		if page.Current+1 < len(page.Tracks) {
+			page.Next = page.Tracks[page.Current+1].Player
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	var err = playerTemplate.Execute(w, page)
+	if err != nil {
+		log.Error().Err(err).Msg("responder player")
+		return
+	}
+}
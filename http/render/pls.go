@@ -0,0 +1,64 @@
+package render
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+func PLS(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var err error
+	var buf = bufio.NewWriter(w)
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".pls"))
+	w.Header().Set("Content-Type", "audio/x-scpls; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	_, err = buf.WriteString("[playlist]\r\n")
+	if err != nil {
+		log.Error().Err(err).Msg("responder pls header")
+		return
+	}
+
+	for i, itm := range list.Content {
+		var n = strconv.Itoa(i + 1)
+
+		var duration int64 = -1
+		if itm.Duration > 0 {
+			duration = int64(math.Round(itm.Duration))
+		}
+
+		_, err = buf.WriteString(
+			"File" + n + "=" + itm.URL + "\r\n" +
+				"Title" + n + "=" + itm.Name + "\r\n" +
+				"Length" + n + "=" + strconv.FormatInt(duration, 10) + "\r\n",
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("responder pls item")
+			return
+		}
+	}
+
+	_, err = buf.WriteString(
+		"NumberOfEntries=" + strconv.Itoa(len(list.Content)) + "\r\n" +
+			"Version=2\r\n",
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("responder pls footer")
+		return
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		log.Error().Err(err).Msg("responder pls: flush buffer")
+		return
+	}
+}
@@ -2,16 +2,13 @@ package render
 
 import (
 	"bufio"
-	"html"
+	"math"
 	"net/http"
-	"net/url"
 	"strconv"
-	"strings"
 
 	"github.com/go-chi/render"
 	"github.com/rs/zerolog/log"
 
-	"github.com/WinPooh32/peerstohttp/http/host"
 	"github.com/WinPooh32/peerstohttp/playlist"
 )
 
@@ -19,9 +16,8 @@ func M3U(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
 	var err error
 	var buf = bufio.NewWriter(w)
 	var items = list.Content
-	var host = r.Context().Value(host.ContextKeyHost).(string)
 
-	w.Header().Set("Content-Disposition", "filename=\""+url.PathEscape(list.Header.Name)+".m3u8\"")
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".m3u8"))
 	w.Header().Set("Content-Type", "application/x-mpegURL; charset=utf-8")
 	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
 		w.WriteHeader(status)
@@ -37,16 +33,9 @@ func M3U(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
 	}
 
 	for _, itm := range items {
-		var name = list.Header.Name
-		var hash = list.Header.Hash
-		var path = strings.Join(itm.Path, "/")
 		var duration int64 = -1
-
-		var contentURL = hash
-		if list.Header.Files <= 1 {
-			contentURL += "/" + url.PathEscape(name)
-		} else {
-			contentURL += "/" + url.PathEscape(name) + "/" + url.PathEscape(path)
+		if itm.Duration > 0 {
+			duration = int64(math.Round(itm.Duration))
 		}
 
 		var displayName string
@@ -56,110 +45,85 @@ func M3U(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
 			displayName = itm.Name
 		}
 
+		var group = itemGroup(list, itm)
+
+		var logo = itm.Cover
+		if logo == "" {
+			logo = itm.Thumbnail
+		}
+
 		_, err = buf.WriteString(
-			"#EXTINF:" + strconv.FormatInt(duration, 10) + "," + displayName + "\r\n" +
-				host + "/content/" + contentURL + "\r\n",
+			"#EXTINF:" + strconv.FormatInt(duration, 10) +
+				m3uAttr("tvg-name", itm.Name) +
+				m3uAttr("tvg-logo", logo) +
+				m3uAttr("group-title", group) +
+				"," + m3uText(displayName) + "\r\n" +
+				"#EXTGRP:" + m3uText(group) + "\r\n",
 		)
 		if err != nil {
 			log.Error().Err(err).Msg("responder m3u item")
 			return
 		}
-	}
 
-	err = buf.Flush()
-	if err != nil {
-		log.Error().Err(err).Msg("responder m3u: flush buffer")
-		return
-	}
-}
-
-func HTML(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
-	var err error
-	var buf = bufio.NewWriter(w)
-	var items = list.Content
-
-	w.Header().Set("Content-Disposition", "filename=\""+url.PathEscape(list.Header.Name)+".html\"")
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
-		w.WriteHeader(status)
-	}
+		if itm.Album != "" {
+			_, err = buf.WriteString("#EXTALB:" + m3uText(itm.Album) + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item album")
+				return
+			}
+		}
 
-	_, err = buf.WriteString(` <!DOCTYPE html>
-<html>
-<head>
-<title>` + html.EscapeString(list.Header.Name) + `</title>
-<meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
-</head>
+		if itm.Artist != "" {
+			_, err = buf.WriteString("#EXTART:" + m3uText(itm.Artist) + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item artist")
+				return
+			}
+		}
 
-<body>
-`)
-	if err != nil {
-		log.Error().Err(err).Msg("responder html header")
-		return
-	}
+		if logo != "" {
+			_, err = buf.WriteString("#EXTIMG:" + logo + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item cover")
+				return
+			}
+		}
 
-	for _, itm := range items {
-		var name = list.Header.Name
-		var hash = list.Header.Hash
-		var path = strings.Join(itm.Path, "/")
+		// Play cue sheet tracks by offsets.
+		if itm.Start > 0 {
+			_, err = buf.WriteString("#EXTVLCOPT:start-time=" + strconv.FormatFloat(itm.Start, 'f', 3, 64) + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item start time")
+				return
+			}
+		}
+		if itm.End > 0 {
+			_, err = buf.WriteString("#EXTVLCOPT:stop-time=" + strconv.FormatFloat(itm.End, 'f', 3, 64) + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item stop time")
+				return
+			}
+		}
 
-		var contentURL = hash
-		if list.Header.Files <= 1 {
-			contentURL += "/" + url.PathEscape(name)
-		} else {
-			contentURL += "/" + url.PathEscape(name) + "/" + url.PathEscape(path)
+		// Attach subtitles for VLC.
+		for _, sub := range itm.Subtitles {
+			_, err = buf.WriteString("#EXTVLCOPT:input-slave=" + sub.URL + "\r\n")
+			if err != nil {
+				log.Error().Err(err).Msg("responder m3u item subtitles")
+				return
+			}
 		}
 
-		_, err = buf.WriteString(
-			`<a href="/content/` + contentURL + `">` + path + `</a></br>`,
-		)
+		_, err = buf.WriteString(itm.URL + "\r\n")
 		if err != nil {
-			log.Error().Err(err).Msg("responder html item")
+			log.Error().Err(err).Msg("responder m3u item")
 			return
 		}
 	}
 
-	_, err = buf.WriteString(`</body>
-</html>`)
-	if err != nil {
-		log.Error().Err(err).Msg("responder html")
-		return
-	}
-
 	err = buf.Flush()
 	if err != nil {
-		log.Error().Err(err).Msg("responder html: flush buffer")
-		return
-	}
-}
-
-func Responder(w http.ResponseWriter, r *http.Request, v interface{}) {
-	var list, ok = v.(*playlist.PlayList)
-	if !ok {
-		log.Error().Msg("responder: expected playlist.PlayList")
+		log.Error().Err(err).Msg("responder m3u: flush buffer")
 		return
 	}
-
-	// Format response based on request Accept header.
-	switch GetAcceptedContentType(r) {
-	case ContentTypeJSON:
-		render.JSON(w, r, list)
-	case ContentTypeHTML:
-		HTML(w, r, list)
-	case ContentTypeM3U:
-		M3U(w, r, list)
-	default:
-		render.JSON(w, r, list)
-	}
-}
-
-// SetListResponder is a middleware that switches default chi responder to list responder.
-func SetListResponder(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		render.Respond = Responder
-		defer func() {
-			render.Respond = render.DefaultResponder
-		}()
-		next.ServeHTTP(w, r)
-	})
 }
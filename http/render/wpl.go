@@ -0,0 +1,64 @@
+package render
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+type wplPlaylist struct {
+	XMLName xml.Name   `xml:"smil"`
+	Meta    []wplMeta  `xml:"head>meta"`
+	Title   string     `xml:"head>title"`
+	Media   []wplMedia `xml:"body>seq>media"`
+}
+
+type wplMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+}
+
+type wplMedia struct {
+	Src string `xml:"src,attr"`
+}
+
+func WPL(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var doc = wplPlaylist{
+		Meta: []wplMeta{
+			{Name: "Generator", Content: "peerstohttp"},
+			{Name: "ItemCount", Content: strconv.Itoa(len(list.Content))},
+		},
+		Title: list.Header.Name,
+		Media: make([]wplMedia, 0, len(list.Content)),
+	}
+
+	for _, itm := range list.Content {
+		doc.Media = append(doc.Media, wplMedia{Src: itm.URL})
+	}
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".wpl"))
+	w.Header().Set("Content-Type", "application/vnd.ms-wpl; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	var _, err = w.Write([]byte("<?wpl version=\"1.0\"?>\n"))
+	if err != nil {
+		log.Error().Err(err).Msg("responder wpl header")
+		return
+	}
+
+	var enc = xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = enc.Encode(doc)
+	if err != nil {
+		log.Error().Err(err).Msg("responder wpl")
+		return
+	}
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"encoding/xml"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/playlist"
+)
+
+const vlcApplication = "http://www.videolan.org/vlc/playlist/0"
+
+type xspfPlaylist struct {
+	XMLName  xml.Name    `xml:"playlist"`
+	Version  string      `xml:"version,attr"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	XMLNSVLC string      `xml:"xmlns:vlc,attr"`
+	Title    string      `xml:"title,omitempty"`
+	Tracks   []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	TrackNum int    `xml:"trackNum,omitempty"`
+	// Duration in milliseconds.
+	Duration  int64          `xml:"duration,omitempty"`
+	Image     string         `xml:"image,omitempty"`
+	Extension *xspfExtension `xml:"extension,omitempty"`
+}
+
+// xspfExtension holds VLC options of cue sheet tracks.
+type xspfExtension struct {
+	Application string   `xml:"application,attr"`
+	Options     []string `xml:"vlc:option"`
+}
+
+func XSPF(w http.ResponseWriter, r *http.Request, list *playlist.PlayList) {
+	var doc = xspfPlaylist{
+		Version:  "1",
+		XMLNS:    "http://xspf.org/ns/0/",
+		XMLNSVLC: vlcApplication,
+		Title:    list.Header.Name,
+		Tracks:   make([]xspfTrack, 0, len(list.Content)),
+	}
+
+	for _, itm := range list.Content {
+		var track = xspfTrack{
+			Location: itm.URL,
+			Title:    itm.Name,
+			Creator:  itm.Artist,
+			Album:    itm.Album,
+			TrackNum: itm.Track,
+			Duration: int64(math.Round(itm.Duration * 1000)),
+			Image:    itm.Cover,
+		}
+
+		if track.Image == "" {
+			track.Image = itm.Thumbnail
+		}
+
+		if itm.Start > 0 || itm.End > 0 {
+			track.Extension = &xspfExtension{Application: vlcApplication}
+
+			if itm.Start > 0 {
+				track.Extension.Options = append(track.Extension.Options, "start-time="+strconv.FormatFloat(itm.Start, 'f', 3, 64))
+			}
+			if itm.End > 0 {
+				track.Extension.Options = append(track.Extension.Options, "stop-time="+strconv.FormatFloat(itm.End, 'f', 3, 64))
+			}
+		}
+
+		doc.Tracks = append(doc.Tracks, track)
+	}
+
+	w.Header().Set("Content-Disposition", attachment(list.Header.Name+".xspf"))
+	w.Header().Set("Content-Type", "application/xspf+xml; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	var _, err = w.Write([]byte(xml.Header))
+	if err != nil {
+		log.Error().Err(err).Msg("responder xspf header")
+		return
+	}
+
+	var enc = xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = enc.Encode(doc)
+	if err != nil {
+		log.Error().Err(err).Msg("responder xspf")
+		return
+	}
+}
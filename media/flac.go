@@ -0,0 +1,75 @@
+package media
+
+import (
+	"io"
+)
+
+const (
+	flacStreamInfo = 0
+)
+
+// flacBlocks calls fn for every metadata block of the FLAC stream at the offset until fn returns false.
+func flacBlocks(r io.ReaderAt, off int64, fn func(typ byte, off int64, length int) (bool, error)) error {
+	var magic, err = readAt(r, off, 4)
+	if err != nil {
+		return err
+	}
+	if string(magic) != "fLaC" {
+		return ErrMalformed
+	}
+
+	off += 4
+
+	for {
+		var h, err = readAt(r, off, 4)
+		if err != nil {
+			return err
+		}
+
+		var last = h[0]&0x80 != 0
+		var typ = h[0] & 0x7f
+		var length = int(h[1])<<16 | int(h[2])<<8 | int(h[3])
+
+		next, err := fn(typ, off+4, length)
+		if err != nil || !next || last {
+			return err
+		}
+
+		off += 4 + int64(length)
+	}
+}
+
+func probeFLAC(r io.ReaderAt, size int64) (Info, error) {
+	var start, err = id3v2Size(r)
+	if err != nil {
+		return Info{}, err
+	}
+
+	var info Info
+
+	err = flacBlocks(r, start, func(typ byte, off int64, length int) (bool, error) {
+		if typ != flacStreamInfo {
+			return true, nil
+		}
+		if length < 18 {
+			return false, ErrMalformed
+		}
+
+		var b, err = readAt(r, off, 18)
+		if err != nil {
+			return false, err
+		}
+
+		// 20 bits of sample rate, 3 bits of channels, 5 bits of bits per sample and 36 bits of samples.
+		var rate = int(b[10])<<12 | int(b[11])<<4 | int(b[12])>>4
+		var samples = int64(b[13]&0x0f)<<32 | int64(be.Uint32(b[14:18]))
+
+		if rate > 0 {
+			info.Duration = float64(samples) / float64(rate)
+		}
+
+		return false, nil
+	})
+
+	return info, err
+}
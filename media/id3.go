@@ -0,0 +1,186 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/WinPooh32/peerstohttp/charset"
+)
+
+// Frame IDs of text tags by ID3v2 major version 2 and versions 3, 4.
+var id3Frames = map[string]string{
+	"TT2": "title", "TIT2": "title",
+	"TP1": "artist", "TPE1": "artist",
+	"TAL": "album", "TALB": "album",
+	"TRK": "track", "TRCK": "track",
+	"TPA": "disc", "TPOS": "disc",
+}
+
+// Limits frames read of ID3v2 tag.
+const maxID3Frames = 1 << 10
+
+// id3v2Frames calls fn for every frame of ID3v2 tag at the file start until fn returns false.
+// Frames are read one by one, data of frames larger than max is nil.
+func id3v2Frames(r io.ReaderAt, max int, fn func(id string, data []byte) bool) error {
+	var size, err = id3v2Size(r)
+	if err != nil || size == 0 {
+		return err
+	}
+
+	h, err := readAt(r, 0, 10)
+	if err != nil {
+		return err
+	}
+
+	var version = h[3]
+	var flags = h[5]
+	var pos int64 = 10
+
+	if flags&0x40 != 0 && version >= 3 {
+		// Skip extended header.
+		ext, err := readAt(r, pos, 4)
+		if err != nil {
+			return err
+		}
+
+		if version == 4 {
+			pos += int64(syncsafe(ext))
+		} else {
+			pos += int64(be.Uint32(ext)) + 4
+		}
+	}
+
+	var idLen, headerLen = 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for frames := 0; pos+int64(headerLen) <= size && frames < maxID3Frames; frames++ {
+		var h, err = readAt(r, pos, headerLen)
+		if err != nil {
+			return err
+		}
+
+		if h[0] == 0 {
+			// Padding.
+			break
+		}
+
+		var id = string(h[:idLen])
+		var frameSize int64
+
+		switch version {
+		case 2:
+			frameSize = int64(h[3])<<16 | int64(h[4])<<8 | int64(h[5])
+		case 4:
+			frameSize = int64(syncsafe(h[4:8]))
+		default:
+			frameSize = int64(be.Uint32(h[4:8]))
+		}
+
+		pos += int64(headerLen)
+		if pos+frameSize > size {
+			return ErrMalformed
+		}
+
+		var data []byte
+		if frameSize <= int64(max) {
+			data, err = readAt(r, pos, int(frameSize))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !fn(id, data) {
+			return nil
+		}
+
+		pos += frameSize
+	}
+
+	return nil
+}
+
+// id3Text decodes text frame content prefixed with the encoding byte.
+func id3Text(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+
+	var enc, text = data[0], data[1:]
+	var s string
+
+	switch enc {
+	case 1:
+		s = charset.DecodeCharset(text, charset.Detect(text))
+	case 2:
+		s = charset.DecodeCharset(text, charset.UTF16BE)
+	case 3:
+		s = charset.DecodeCharset(text, charset.UTF8)
+	default:
+		// ISO-8859-1 is often abused for legacy local encodings.
+		s = charset.Decode(text)
+	}
+
+	// Multiple values are separated by null.
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s)
+}
+
+func readID3v2(r io.ReaderAt, info *Info) error {
+	return id3v2Frames(r, maxTagSize, func(id string, data []byte) bool {
+		if field, ok := id3Frames[id]; ok {
+			info.setTag(field, id3Text(data))
+		}
+		if id == "APIC" || id == "PIC" {
+			info.Picture = true
+		}
+		return true
+	})
+}
+
+func readID3v1(r io.ReaderAt, size int64, info *Info) error {
+	var tag, _, err = readTail(r, size, 128)
+	if err != nil {
+		return err
+	}
+	if len(tag) < 128 || !bytes.HasPrefix(tag, []byte("TAG")) {
+		return nil
+	}
+
+	var field = func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(charset.Decode(b))
+	}
+
+	info.setTag("title", field(tag[3:33]))
+	info.setTag("artist", field(tag[33:63]))
+	info.setTag("album", field(tag[63:93]))
+
+	// ID3v1.1 keeps track number at the end of comment.
+	if tag[125] == 0 && tag[126] != 0 && info.Track == 0 {
+		info.Track = int(tag[126])
+	}
+
+	return nil
+}
+
+// readMP3Tags prefers ID3v2 falling back to ID3v1 for missing fields.
+func readMP3Tags(r io.ReaderAt, size int64, info *Info) error {
+	var err = readID3v2(r, info)
+	if err != nil {
+		return err
+	}
+
+	if info.Title == "" || info.Artist == "" || info.Album == "" {
+		return readID3v1(r, size, info)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits read size of text tags.
+const maxTagSize = 64 << 10
+
+var (
+	ErrUnsupported = errors.New("unsupported media format")
+	ErrMalformed   = errors.New("malformed media file")
+)
+
+// Info is metadata of the media file.
+type Info struct {
+	// Duration in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	// Embedded tags.
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Track  int    `json:"track,omitempty"`
+	Disc   int    `json:"disc,omitempty"`
+
+	// Picture reports whether the file has embedded cover art.
+	Picture bool `json:"picture,omitempty"`
+}
+
+// setTag sets the empty field by name, numbers are parsed from values like "3/12".
+func (info *Info) setTag(field, value string) {
+	if value == "" {
+		return
+	}
+
+	switch field {
+	case "title":
+		if info.Title == "" {
+			info.Title = value
+		}
+	case "artist":
+		if info.Artist == "" {
+			info.Artist = value
+		}
+	case "album":
+		if info.Album == "" {
+			info.Album = value
+		}
+	case "track", "disc":
+		var num, _, _ = strings.Cut(value, "/")
+		var n, err = strconv.Atoi(strings.TrimSpace(num))
+		if err == nil {
+			info.setNumber(field, n)
+		}
+	}
+}
+
+func (info *Info) setNumber(field string, n int) {
+	switch {
+	case n <= 0:
+	case field == "track" && info.Track == 0:
+		info.Track = n
+	case field == "disc" && info.Disc == 0:
+		info.Disc = n
+	}
+}
+
+type prober func(r io.ReaderAt, size int64) (Info, error)
+
+type tagReader func(r io.ReaderAt, size int64, info *Info) error
+
+var probers = map[string]prober{
+	".mp3":  probeMP3,
+	".flac": probeFLAC,
+	".mp4":  probeMP4,
+	".m4a":  probeMP4,
+	".m4b":  probeMP4,
+	".m4v":  probeMP4,
+	".mov":  probeMP4,
+	".mkv":  probeMKV,
+	".mka":  probeMKV,
+	".webm": probeMKV,
+	".ogg":  probeOgg,
+	".oga":  probeOgg,
+	".opus": probeOgg,
+}
+
+var tagReaders = map[string]tagReader{
+	".mp3":  readMP3Tags,
+	".flac": readFLACTags,
+	".mp4":  readMP4Tags,
+	".m4a":  readMP4Tags,
+	".m4b":  readMP4Tags,
+	".ogg":  readOggTags,
+	".oga":  readOggTags,
+	".opus": readOggTags,
+}
+
+// Supported reports whether the file extension is supported by Probe.
+func Supported(ext string) bool {
+	var _, ok = probers[strings.ToLower(ext)]
+	return ok
+}
+
+// Probe reads duration from the container headers and embedded tags of the file with the extension.
+// Only a few small ranges of the file are read.
+func Probe(r io.ReaderAt, size int64, ext string) (Info, error) {
+	ext = strings.ToLower(ext)
+
+	var probe, ok = probers[ext]
+	if !ok {
+		return Info{}, ErrUnsupported
+	}
+
+	var info, err = probe(r, size)
+	if err != nil && !errors.Is(err, ErrMalformed) {
+		return info, err
+	}
+
+	if readTags, ok := tagReaders[ext]; ok {
+		// Tags are optional.
+		var tagsErr = readTags(r, size, &info)
+		if tagsErr != nil && !errors.Is(tagsErr, ErrMalformed) && !errors.Is(tagsErr, ErrUnsupported) {
+			return info, tagsErr
+		}
+	}
+
+	return info, err
+}
+
+// ReadTags reads only embedded tags of the file with the extension.
+// Tags are kept at the file start or end, so it's faster than Probe.
+func ReadTags(r io.ReaderAt, size int64, ext string) (Info, error) {
+	var readTags, ok = tagReaders[strings.ToLower(ext)]
+	if !ok {
+		return Info{}, ErrUnsupported
+	}
+
+	var info Info
+	var err = readTags(r, size, &info)
+
+	return info, err
+}
+
+// readAt reads exactly n bytes at the offset.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	var buf = make([]byte, n)
+
+	var _, err = r.ReadAt(buf, off)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// readTail reads at most n bytes from the end of the file.
+func readTail(r io.ReaderAt, size int64, n int) ([]byte, int64, error) {
+	if int64(n) > size {
+		n = int(size)
+	}
+
+	var off = size - int64(n)
+	var buf, err = readAt(r, off, n)
+
+	return buf, off, err
+}
+
+// id3v2Size returns size of ID3v2 tag at the file start or zero when there is no tag.
+func id3v2Size(r io.ReaderAt) (int64, error) {
+	var h, err = readAt(r, 0, 10)
+	if err != nil {
+		return 0, err
+	}
+
+	if string(h[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	var size = int64(syncsafe(h[6:10])) + 10
+	if h[5]&0x10 != 0 {
+		// Footer is present.
+		size += 10
+	}
+
+	return size, nil
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+var (
+	be = binary.BigEndian
+	le = binary.LittleEndian
+)
@@ -0,0 +1,506 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func u16(v uint16) []byte  { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte  { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte  { return binary.BigEndian.AppendUint64(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// id3v2 builds ID3v2.3 tag of the frames.
+func id3v2(frames ...[]byte) []byte {
+	var body = join(frames...)
+	var n = len(body)
+	return join([]byte("ID3\x03\x00\x00"), []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}, body)
+}
+
+func id3Frame(id string, data []byte) []byte {
+	return join([]byte(id), u32(uint32(len(data))), []byte{0, 0}, data)
+}
+
+// mp3Audio builds 128 kbit/s MPEG-1 layer III stereo stream of n bytes.
+func mp3Audio(n int) []byte {
+	var b = make([]byte, n)
+	copy(b, []byte{0xff, 0xfb, 0x90, 0x00})
+	return b
+}
+
+func flacBlock(typ byte, last bool, data []byte) []byte {
+	if last {
+		typ |= 0x80
+	}
+	var n = len(data)
+	return join([]byte{typ, byte(n >> 16), byte(n >> 8), byte(n)}, data)
+}
+
+// streamInfo builds STREAMINFO of 44.1 kHz stream of the samples.
+func streamInfo(samples uint32) []byte {
+	var b = make([]byte, 34)
+	b[10], b[11], b[12] = 0x0a, 0xc4, 0x40
+	binary.BigEndian.PutUint32(b[14:18], samples)
+	return b
+}
+
+func vorbisComment(comments ...string) []byte {
+	var b = join(le32(6), []byte("vendor"), le32(uint32(len(comments))))
+	for _, c := range comments {
+		b = join(b, le32(uint32(len(c))), []byte(c))
+	}
+	return b
+}
+
+func flacPictureBlock(typ uint32, data []byte) []byte {
+	return join(u32(typ), u32(9), []byte("image/png"), u32(0), make([]byte, 16), u32(uint32(len(data))), data)
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	var body = join(payload...)
+	return join(u32(uint32(8+len(body))), []byte(typ), body)
+}
+
+// mvhd builds version 0 movie header.
+func mvhd(timescale, duration uint32) []byte {
+	var b = make([]byte, 100)
+	binary.BigEndian.PutUint32(b[12:16], timescale)
+	binary.BigEndian.PutUint32(b[16:20], duration)
+	return box("mvhd", b)
+}
+
+func mp4Data(value []byte) []byte {
+	return box("data", u32(1), u32(0), value)
+}
+
+func mp4Ilst(items ...[]byte) []byte {
+	return box("udta", box("meta", u32(0), box("ilst", items...)))
+}
+
+// ebml builds element of the id with one byte or eight bytes size.
+func ebml(id []byte, payload ...[]byte) []byte {
+	var body = join(payload...)
+	if len(body) < 0x7f {
+		return join(id, []byte{0x80 | byte(len(body))}, body)
+	}
+	return join(id, []byte{0x01}, u64(uint64(len(body)))[1:], body)
+}
+
+var (
+	idEBML          = []byte{0x1a, 0x45, 0xdf, 0xa3}
+	idSegment       = []byte{0x18, 0x53, 0x80, 0x67}
+	idInfo          = []byte{0x15, 0x49, 0xa9, 0x66}
+	idTimecodeScale = []byte{0x2a, 0xd7, 0xb1}
+	idDuration      = []byte{0x44, 0x89}
+	idCluster       = []byte{0x1f, 0x43, 0xb6, 0x75}
+)
+
+func mkvInfo(duration float64) []byte {
+	return ebml(idInfo,
+		ebml(idTimecodeScale, []byte{0x0f, 0x42, 0x40}),
+		ebml(idDuration, u64(math.Float64bits(duration))),
+	)
+}
+
+func oggPageData(granule uint64, payload []byte) []byte {
+	var lacing []byte
+	for n := len(payload); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+
+	var h = make([]byte, 27)
+	copy(h, "OggS")
+	binary.LittleEndian.PutUint64(h[6:14], granule)
+	h[26] = byte(len(lacing))
+
+	return join(h, lacing, payload)
+}
+
+func vorbisID(rate uint32) []byte {
+	return join([]byte("\x01vorbis"), le32(0), []byte{2}, le32(rate), make([]byte, 14))
+}
+
+func opusHead(preskip uint16) []byte {
+	return join([]byte("OpusHead\x01\x02"), []byte{byte(preskip), byte(preskip >> 8)}, le32(48000), make([]byte, 3))
+}
+
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+func TestProbe(t *testing.T) {
+	var bigText = join([]byte{3}, bytes.Repeat([]byte("a"), maxTagSize+1))
+
+	var tests = []struct {
+		name string
+		ext  string
+		data []byte
+		want Info
+		err  error
+	}{
+		{
+			name: "mp3 cbr with id3v2",
+			ext:  ".mp3",
+			data: join(id3v2(
+				id3Frame("TIT2", []byte("\x03Title")),
+				id3Frame("TPE1", []byte("\x03Artist")),
+				id3Frame("TRCK", []byte("\x033/12")),
+			), mp3Audio(16000)),
+			want: Info{Duration: 1, Title: "Title", Artist: "Artist", Track: 3},
+		},
+		{
+			name: "mp3 xing frame count",
+			ext:  ".mp3",
+			data: join(mp3Audio(36), []byte("Xing"), u32(1), u32(100), make([]byte, 100)),
+			want: Info{Duration: 100 * 1152 / 44100.0},
+		},
+		{
+			name: "mp3 id3v1 fallback",
+			ext:  ".mp3",
+			data: join(mp3Audio(16000-128), []byte("TAG"), []byte("Old title"), make([]byte, 116)),
+			want: Info{Duration: float64(16000-128) * 8 / 128000, Title: "Old title"},
+		},
+		{
+			name: "mp3 id3v2 beyond the file",
+			ext:  ".mp3",
+			data: join([]byte("ID3\x03\x00\x00\x00\x00\x7f\x7f"), mp3Audio(100)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp3 frame beyond the tag",
+			ext:  ".mp3",
+			data: join(id3v2(join([]byte("TIT2"), u32(1000), []byte{0, 0}, []byte("\x03Title"))), mp3Audio(16000)),
+			want: Info{Duration: 1},
+		},
+		{
+			name: "mp3 oversized frame is skipped",
+			ext:  ".mp3",
+			data: join(id3v2(id3Frame("TIT2", bigText), id3Frame("TPE1", []byte("\x03Artist"))), mp3Audio(16000)),
+			want: Info{Duration: 1, Artist: "Artist"},
+		},
+		{
+			name: "mp3 without frames",
+			ext:  ".mp3",
+			data: make([]byte, 1000),
+			err:  ErrMalformed,
+		},
+		{
+			name: "flac",
+			ext:  ".flac",
+			data: join([]byte("fLaC"),
+				flacBlock(flacStreamInfo, false, streamInfo(441000)),
+				flacBlock(flacVorbisComment, false, vorbisComment("TITLE=Song", "ARTIST=Band", "TRACKNUMBER=2")),
+				flacBlock(flacPicture, true, flacPictureBlock(3, pngData)),
+			),
+			want: Info{Duration: 10, Title: "Song", Artist: "Band", Track: 2, Picture: true},
+		},
+		{
+			name: "flac truncated stream info",
+			ext:  ".flac",
+			data: join([]byte("fLaC"), flacBlock(flacStreamInfo, true, make([]byte, 10))),
+			err:  ErrMalformed,
+		},
+		{
+			name: "flac block beyond the file",
+			ext:  ".flac",
+			data: join([]byte("fLaC"), []byte{0x80, 0x00, 0x00, 0x22}, make([]byte, 10)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "flac oversized vorbis comment is cut",
+			ext:  ".flac",
+			data: join([]byte("fLaC"),
+				flacBlock(flacStreamInfo, false, streamInfo(44100)),
+				flacBlock(flacVorbisComment, true, vorbisComment("TITLE=Song", "COMMENT="+string(bytes.Repeat([]byte("a"), maxTagSize)))),
+			),
+			want: Info{Duration: 1, Title: "Song"},
+		},
+		{
+			name: "flac without magic",
+			ext:  ".flac",
+			data: make([]byte, 100),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp4",
+			ext:  ".m4a",
+			data: join(box("ftyp", []byte("M4A ")), box("moov", mvhd(1000, 5000), mp4Ilst(
+				box("\xa9nam", mp4Data([]byte("Title"))),
+				box("\xa9alb", mp4Data([]byte("Album"))),
+				box("trkn", mp4Data(join(u16(0), u16(4), u16(10)))),
+				box("covr", mp4Data(pngData)),
+			))),
+			want: Info{Duration: 5, Title: "Title", Album: "Album", Track: 4, Picture: true},
+		},
+		{
+			name: "mp4 64-bit box size",
+			ext:  ".mp4",
+			data: join(u32(1), []byte("moov"), u64(16+108), mvhd(600, 1200)),
+			want: Info{Duration: 2},
+		},
+		{
+			name: "mp4 oversized data is skipped",
+			ext:  ".m4a",
+			data: box("moov", mvhd(1000, 5000), mp4Ilst(
+				box("\xa9nam", mp4Data(bytes.Repeat([]byte("a"), maxTagSize))),
+				box("\xa9ART", mp4Data([]byte("Artist"))),
+			)),
+			want: Info{Duration: 5, Artist: "Artist"},
+		},
+		{
+			name: "mp4 box beyond the file",
+			ext:  ".mp4",
+			data: join(u32(1000), []byte("moov"), mvhd(1000, 5000)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp4 box smaller than its header",
+			ext:  ".mp4",
+			data: join(u32(4), []byte("moov"), mvhd(1000, 5000)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp4 nested box of huge size",
+			ext:  ".mp4",
+			data: box("moov", u32(1), []byte("mvhd"), u64(math.MaxInt64), make([]byte, 100)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp4 truncated movie header",
+			ext:  ".mp4",
+			data: box("moov", box("mvhd", make([]byte, 10))),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mp4 zero timescale",
+			ext:  ".mp4",
+			data: box("moov", mvhd(0, 5000)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mkv",
+			ext:  ".mkv",
+			data: join(ebml(idEBML), ebml(idSegment, mkvInfo(2500), ebml(idCluster))),
+			want: Info{Duration: 2.5},
+		},
+		{
+			name: "mkv segment of unknown size",
+			ext:  ".webm",
+			data: join(ebml(idEBML), idSegment, []byte{0xff}, mkvInfo(1500)),
+			want: Info{Duration: 1.5},
+		},
+		{
+			name: "mkv large element size",
+			ext:  ".mkv",
+			data: join(ebml(idEBML, make([]byte, 200)), ebml(idSegment, mkvInfo(1000))),
+			want: Info{Duration: 1},
+		},
+		{
+			name: "mkv segment beyond the file",
+			ext:  ".mkv",
+			data: join(ebml(idEBML), idSegment, []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00}, mkvInfo(1000)),
+			want: Info{Duration: 1},
+		},
+		{
+			name: "mkv truncated info",
+			ext:  ".mkv",
+			data: join(ebml(idEBML), ebml(idSegment, idInfo, []byte{0x90}, idDuration)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "mkv invalid element id",
+			ext:  ".mkv",
+			data: []byte{0x00, 0x00, 0x00, 0x00},
+			err:  ErrMalformed,
+		},
+		{
+			name: "mkv without info",
+			ext:  ".mkv",
+			data: join(ebml(idEBML), ebml(idSegment, ebml(idCluster))),
+			err:  ErrMalformed,
+		},
+		{
+			name: "ogg vorbis",
+			ext:  ".ogg",
+			data: join(
+				oggPageData(0, vorbisID(44100)),
+				oggPageData(0, join([]byte("\x03vorbis"), vorbisComment("title=Song", "album=Album"))),
+				oggPageData(3*44100, make([]byte, 300)),
+			),
+			want: Info{Duration: 3, Title: "Song", Album: "Album"},
+		},
+		{
+			name: "ogg opus",
+			ext:  ".opus",
+			data: join(
+				oggPageData(0, opusHead(312)),
+				oggPageData(0, join([]byte("OpusTags"), vorbisComment("ARTIST=Band"))),
+				oggPageData(48000+312, make([]byte, 300)),
+			),
+			want: Info{Duration: 1, Artist: "Band"},
+		},
+		{
+			name: "ogg comment beyond the window",
+			ext:  ".ogg",
+			data: join(
+				oggPageData(0, vorbisID(44100)),
+				oggPageData(0, join([]byte("\x03vorbis"), le32(0xffffff))),
+				oggPageData(44100, make([]byte, 300)),
+			),
+			want: Info{Duration: 1},
+		},
+		{
+			name: "ogg without granule",
+			ext:  ".ogg",
+			data: join(oggPageData(0, vorbisID(44100)), oggPageData(0, make([]byte, 300))),
+			err:  ErrMalformed,
+		},
+		{
+			name: "ogg truncated",
+			ext:  ".ogg",
+			data: oggPageData(0, vorbisID(44100)),
+			err:  ErrMalformed,
+		},
+		{
+			name: "unsupported",
+			ext:  ".wav",
+			data: make([]byte, 100),
+			err:  ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info, err = Probe(bytes.NewReader(tt.data), int64(len(tt.data)), tt.ext)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			if math.Abs(info.Duration-tt.want.Duration) > 1e-6 {
+				t.Errorf("duration = %v, want %v", info.Duration, tt.want.Duration)
+			}
+
+			info.Duration = tt.want.Duration
+			if info != tt.want {
+				t.Errorf("info = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPicture(t *testing.T) {
+	var gifData = []byte("GIF89a\x01\x00\x01\x00")
+	var bigPicture = make([]byte, maxPictureSize+1)
+
+	var tests = []struct {
+		name string
+		ext  string
+		data []byte
+		want []byte
+		err  error
+	}{
+		{
+			name: "id3v2 front cover is preferred",
+			ext:  ".mp3",
+			data: join(id3v2(
+				id3Frame("APIC", join([]byte("\x00image/gif\x00\x04back\x00"), gifData)),
+				id3Frame("APIC", join([]byte("\x00image/png\x00\x03\x00"), pngData)),
+			), mp3Audio(100)),
+			want: pngData,
+		},
+		{
+			name: "id3v2 utf-16 description",
+			ext:  ".mp3",
+			data: join(id3v2(id3Frame("APIC", join([]byte("\x01image/png\x00\x03\xff\xfea\x00\x00\x00"), pngData))), mp3Audio(100)),
+			want: pngData,
+		},
+		{
+			name: "id3v2 truncated frame",
+			ext:  ".mp3",
+			data: join(id3v2(id3Frame("APIC", []byte("\x00image/png"))), mp3Audio(100)),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "id3v2 oversized picture is skipped",
+			ext:  ".mp3",
+			data: join(id3v2(id3Frame("APIC", join([]byte("\x00image/png\x00\x03\x00"), bigPicture))), mp3Audio(100)),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "flac",
+			ext:  ".flac",
+			data: join([]byte("fLaC"),
+				flacBlock(flacStreamInfo, false, streamInfo(44100)),
+				flacBlock(flacPicture, false, flacPictureBlock(0, gifData)),
+				flacBlock(flacPicture, true, flacPictureBlock(pictureFrontCover, pngData)),
+			),
+			want: pngData,
+		},
+		{
+			name: "flac picture data beyond the block",
+			ext:  ".flac",
+			data: join([]byte("fLaC"), flacBlock(flacPicture, true, join(u32(3), u32(9), []byte("image/png"), u32(0), make([]byte, 16), u32(1000), pngData))),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "flac oversized picture is skipped",
+			ext:  ".flac",
+			data: join([]byte("fLaC"), []byte{0x80 | flacPicture, 0x80, 0x00, 0x01}, pngData),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "mp4",
+			ext:  ".m4a",
+			data: box("moov", mvhd(1000, 1000), mp4Ilst(box("covr", mp4Data(pngData)))),
+			want: pngData,
+		},
+		{
+			name: "mp4 oversized picture is skipped",
+			ext:  ".m4a",
+			data: box("moov", mvhd(1000, 1000), mp4Ilst(box("covr", mp4Data(bigPicture)))),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "mp4 without cover",
+			ext:  ".mp4",
+			data: box("moov", mvhd(1000, 1000)),
+			err:  ErrNoPicture,
+		},
+		{
+			name: "unsupported",
+			ext:  ".ogg",
+			data: make([]byte, 100),
+			err:  ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pic, err = ReadPicture(bytes.NewReader(tt.data), int64(len(tt.data)), tt.ext)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			if !bytes.Equal(pic.Data, tt.want) {
+				t.Errorf("data = %q, want %q", pic.Data, tt.want)
+			}
+		})
+	}
+}
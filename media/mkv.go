@@ -0,0 +1,160 @@
+package media
+
+import (
+	"io"
+	"math"
+)
+
+const (
+	ebmlHeader        = 0x1A45DFA3
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlCluster       = 0x1F43B675
+)
+
+// ebmlVint reads variable length integer returning its value and length.
+// Marker bit is kept for element IDs and removed for sizes.
+func ebmlVint(r io.ReaderAt, off int64, id bool) (uint64, int, error) {
+	var first, err = readAt(r, off, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var length = 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, ErrMalformed
+	}
+
+	b, err := readAt(r, off, length)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var v uint64
+	if !id {
+		b[0] &= byte(0xff >> length)
+	}
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	// All ones size means unknown size.
+	if !id && v == 1<<(7*length)-1 {
+		v = math.MaxUint64
+	}
+
+	return v, length, nil
+}
+
+type ebmlElement struct {
+	id uint64
+	// Offset and size of the element data.
+	off  int64
+	size int64
+}
+
+// ebmlElements calls fn for every element inside of the range until fn returns false.
+func ebmlElements(r io.ReaderAt, off, end int64, fn func(e ebmlElement) (bool, error)) error {
+	for off < end {
+		var id, idLen, err = ebmlVint(r, off, true)
+		if err != nil {
+			return err
+		}
+
+		size, sizeLen, err := ebmlVint(r, off+int64(idLen), false)
+		if err != nil {
+			return err
+		}
+
+		var e = ebmlElement{id: id, off: off + int64(idLen+sizeLen)}
+		if size == math.MaxUint64 || e.off+int64(size) > end {
+			e.size = end - e.off
+		} else {
+			e.size = int64(size)
+		}
+
+		next, err := fn(e)
+		if err != nil || !next {
+			return err
+		}
+
+		off = e.off + e.size
+	}
+
+	return nil
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func probeMKV(r io.ReaderAt, size int64) (Info, error) {
+	var info Info
+	var found bool
+
+	var err = ebmlElements(r, 0, size, func(e ebmlElement) (bool, error) {
+		switch e.id {
+		case ebmlHeader:
+			return true, nil
+		case ebmlSegment:
+		default:
+			return false, ErrMalformed
+		}
+
+		return false, ebmlElements(r, e.off, e.off+e.size, func(e ebmlElement) (bool, error) {
+			switch e.id {
+			case ebmlInfo:
+			case ebmlCluster:
+				// Segment info is expected before media data.
+				return false, nil
+			default:
+				return true, nil
+			}
+
+			var scale uint64 = 1000000
+			var duration float64
+
+			var err = ebmlElements(r, e.off, e.off+e.size, func(e ebmlElement) (bool, error) {
+				if e.id != ebmlTimecodeScale && e.id != ebmlDuration || e.size > 8 {
+					return true, nil
+				}
+
+				var b, err = readAt(r, e.off, int(e.size))
+				if err != nil {
+					return false, err
+				}
+
+				switch {
+				case e.id == ebmlTimecodeScale:
+					scale = ebmlUint(b)
+				case e.size == 4:
+					duration = float64(math.Float32frombits(be.Uint32(b)))
+				case e.size == 8:
+					duration = math.Float64frombits(be.Uint64(b))
+				}
+
+				return true, nil
+			})
+
+			found = true
+			info.Duration = duration * float64(scale) / 1e9
+
+			return false, err
+		})
+	})
+
+	if err == nil && !found {
+		err = ErrMalformed
+	}
+
+	return info, err
+}
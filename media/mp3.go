@@ -0,0 +1,160 @@
+package media
+
+import (
+	"bytes"
+	"io"
+)
+
+// Bitrates in kbit/s by version (MPEG-1 or MPEG-2/2.5), layer and index.
+var mp3Bitrates = [2][3][16]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// Sample rates by version index of the frame header.
+var mp3SampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+type mp3Frame struct {
+	mpeg1      bool
+	layer      int
+	bitrate    int
+	sampleRate int
+	mono       bool
+}
+
+func parseMP3Frame(h []byte) (mp3Frame, bool) {
+	if len(h) < 4 || h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+
+	var version = int(h[1]>>3) & 3
+	var layer = 4 - int(h[1]>>1)&3
+	var bitrateIndex = int(h[2] >> 4)
+	var rateIndex = int(h[2]>>2) & 3
+
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	var f = mp3Frame{
+		mpeg1:      version == 3,
+		layer:      layer,
+		sampleRate: mp3SampleRates[version][rateIndex],
+		mono:       h[3]>>6 == 3,
+	}
+
+	var v = 1
+	if f.mpeg1 {
+		v = 0
+	}
+	f.bitrate = mp3Bitrates[v][layer-1][bitrateIndex] * 1000
+
+	return f, true
+}
+
+func (f mp3Frame) samples() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && !f.mpeg1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// sideInfo returns size of layer III side information following the frame header.
+func (f mp3Frame) sideInfo() int {
+	switch {
+	case f.mpeg1 && f.mono:
+		return 17
+	case f.mpeg1:
+		return 32
+	case f.mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// probeMP3 takes the frame count from Xing/Info or VBRI header,
+// otherwise it estimates duration of constant bitrate stream from the first frame.
+func probeMP3(r io.ReaderAt, size int64) (Info, error) {
+	const window = 64 << 10
+
+	var start, err = id3v2Size(r)
+	if err != nil {
+		return Info{}, err
+	}
+	if start >= size {
+		return Info{}, ErrMalformed
+	}
+
+	var n = window
+	if rest := size - start; rest < int64(n) {
+		n = int(rest)
+	}
+
+	buf, err := readAt(r, start, n)
+	if err != nil {
+		return Info{}, err
+	}
+
+	// Find the first valid frame header.
+	var pos = -1
+	var frame mp3Frame
+
+	for i := 0; i+4 <= len(buf); i++ {
+		var ok bool
+		if frame, ok = parseMP3Frame(buf[i:]); ok {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return Info{}, ErrMalformed
+	}
+
+	var frames uint32
+	var body = buf[pos+4:]
+
+	if off := frame.sideInfo(); len(body) >= off+12 {
+		var tag = body[off : off+4]
+		if bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			var flags = be.Uint32(body[off+4:])
+			if flags&1 != 0 {
+				frames = be.Uint32(body[off+8:])
+			}
+		}
+	}
+
+	if frames == 0 && len(body) >= 32+18 && bytes.Equal(body[32:36], []byte("VBRI")) {
+		frames = be.Uint32(body[32+14:])
+	}
+
+	if frames != 0 {
+		return Info{Duration: float64(frames) * float64(frame.samples()) / float64(frame.sampleRate)}, nil
+	}
+
+	// Constant bitrate.
+	var audio = size - start - int64(pos)
+
+	if tail, _, err := readTail(r, size, 128); err == nil && bytes.HasPrefix(tail, []byte("TAG")) {
+		audio -= 128
+	}
+
+	return Info{Duration: float64(audio) * 8 / float64(frame.bitrate)}, nil
+}
@@ -0,0 +1,168 @@
+package media
+
+import (
+	"io"
+	"strings"
+)
+
+type mp4Box struct {
+	typ string
+	// Offset and size of the box payload.
+	off  int64
+	size int64
+}
+
+// mp4Boxes calls fn for every box inside of the range until fn returns false.
+func mp4Boxes(r io.ReaderAt, off, end int64, fn func(b mp4Box) (bool, error)) error {
+	for off+8 <= end {
+		var h, err = readAt(r, off, 8)
+		if err != nil {
+			return err
+		}
+
+		var size = int64(be.Uint32(h))
+		var header = int64(8)
+
+		switch size {
+		case 0:
+			// Box extends to the end.
+			size = end - off
+		case 1:
+			ext, err := readAt(r, off+8, 8)
+			if err != nil {
+				return err
+			}
+			size = int64(be.Uint64(ext))
+			header = 16
+		}
+
+		if size < header || size > end-off {
+			return ErrMalformed
+		}
+
+		next, err := fn(mp4Box{typ: string(h[4:8]), off: off + header, size: size - header})
+		if err != nil || !next {
+			return err
+		}
+
+		off += size
+	}
+
+	return nil
+}
+
+// mp4Find returns the first box of the path like "moov/udta/meta".
+func mp4Find(r io.ReaderAt, off, end int64, path ...string) (mp4Box, bool, error) {
+	var found mp4Box
+	var ok bool
+
+	var err = mp4Boxes(r, off, end, func(b mp4Box) (bool, error) {
+		if b.typ != path[0] {
+			return true, nil
+		}
+
+		if len(path) == 1 {
+			found, ok = b, true
+			return false, nil
+		}
+
+		var payload = b.off
+		if b.typ == "meta" {
+			// Full box: version and flags.
+			payload += 4
+		}
+
+		var err error
+		found, ok, err = mp4Find(r, payload, b.off+b.size, path[1:]...)
+
+		return false, err
+	})
+
+	return found, ok, err
+}
+
+func probeMP4(r io.ReaderAt, size int64) (Info, error) {
+	var mvhd, ok, err = mp4Find(r, 0, size, "moov", "mvhd")
+	if err != nil {
+		return Info{}, err
+	}
+	if !ok {
+		return Info{}, ErrMalformed
+	}
+
+	b, err := readAt(r, mvhd.off, 32)
+	if err != nil {
+		return Info{}, err
+	}
+
+	var timescale uint32
+	var duration uint64
+
+	if b[0] == 1 {
+		timescale = be.Uint32(b[20:24])
+		duration = be.Uint64(b[24:32])
+	} else {
+		timescale = be.Uint32(b[12:16])
+		duration = uint64(be.Uint32(b[16:20]))
+	}
+
+	if timescale == 0 {
+		return Info{}, ErrMalformed
+	}
+
+	return Info{Duration: float64(duration) / float64(timescale)}, nil
+}
+
+// Metadata item atoms of iTunes-style ilst box.
+var mp4Fields = map[string]string{
+	"\xa9nam": "title",
+	"\xa9ART": "artist",
+	"aART":    "artist",
+	"\xa9alb": "album",
+	"trkn":    "track",
+	"disk":    "disc",
+}
+
+func readMP4Tags(r io.ReaderAt, size int64, info *Info) error {
+	var ilst, ok, err = mp4Find(r, 0, size, "moov", "udta", "meta", "ilst")
+	if err != nil || !ok {
+		return err
+	}
+
+	return mp4Boxes(r, ilst.off, ilst.off+ilst.size, func(item mp4Box) (bool, error) {
+		if item.typ == "covr" {
+			info.Picture = true
+			return true, nil
+		}
+
+		var field, ok = mp4Fields[item.typ]
+		if !ok {
+			return true, nil
+		}
+
+		data, ok, err := mp4Find(r, item.off, item.off+item.size, "data")
+		if err != nil || !ok || data.size <= 8 || data.size > maxTagSize {
+			return err == nil, err
+		}
+
+		b, err := readAt(r, data.off, int(data.size))
+		if err != nil {
+			return false, err
+		}
+
+		// Skip type indicator and locale.
+		var value = b[8:]
+
+		switch field {
+		case "track", "disc":
+			// Reserved, number, total.
+			if len(value) >= 4 {
+				info.setNumber(field, int(be.Uint16(value[2:4])))
+			}
+		default:
+			info.setTag(field, strings.TrimSpace(string(value)))
+		}
+
+		return true, nil
+	})
+}
@@ -0,0 +1,95 @@
+package media
+
+import (
+	"bytes"
+	"io"
+)
+
+var oggCapture = []byte("OggS")
+
+// oggPage is a parsed page header.
+type oggPage struct {
+	granule int64
+	// Offset and size of the page payload.
+	off  int64
+	size int64
+}
+
+func parseOggPage(b []byte, off int64) (oggPage, bool) {
+	if len(b) < 27 || !bytes.Equal(b[:4], oggCapture) {
+		return oggPage{}, false
+	}
+
+	var segments = int(b[26])
+	if len(b) < 27+segments {
+		return oggPage{}, false
+	}
+
+	var size int64
+	for _, s := range b[27 : 27+segments] {
+		size += int64(s)
+	}
+
+	return oggPage{
+		granule: int64(le.Uint64(b[6:14])),
+		off:     off + 27 + int64(segments),
+		size:    size,
+	}, true
+}
+
+// oggStream reads sample rate and pre-skip from the identification header of the first page.
+func oggStream(r io.ReaderAt) (rate int, preskip int64, err error) {
+	var h []byte
+
+	h, err = readAt(r, 0, 27+255)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var page, ok = parseOggPage(h, 0)
+	if !ok {
+		return 0, 0, ErrMalformed
+	}
+
+	id, err := readAt(r, page.off, 19)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case bytes.HasPrefix(id, []byte("\x01vorbis")):
+		return int(le.Uint32(id[12:16])), 0, nil
+	case bytes.HasPrefix(id, []byte("OpusHead")):
+		// Opus granule position is always in 48kHz samples.
+		return 48000, int64(le.Uint16(id[10:12])), nil
+	default:
+		return 0, 0, ErrUnsupported
+	}
+}
+
+// probeOgg takes duration from granule position of the last page.
+func probeOgg(r io.ReaderAt, size int64) (Info, error) {
+	const window = 64 << 10
+
+	var rate, preskip, err = oggStream(r)
+	if err != nil {
+		return Info{}, err
+	}
+	if rate == 0 {
+		return Info{}, ErrMalformed
+	}
+
+	tail, off, err := readTail(r, size, window)
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := bytes.LastIndex(tail, oggCapture); i >= 0; i = bytes.LastIndex(tail[:i], oggCapture) {
+		var page, ok = parseOggPage(tail[i:], off+int64(i))
+		if ok && page.granule > 0 {
+			return Info{Duration: float64(page.granule-preskip) / float64(rate)}, nil
+		}
+	}
+
+	return Info{}, ErrMalformed
+}
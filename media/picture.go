@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	flacPicture = 6
+
+	// Larger pictures are skipped.
+	maxPictureSize = 8 << 20
+
+	// Picture type of the front cover by ID3v2 APIC and FLAC PICTURE.
+	pictureFrontCover = 3
+)
+
+var ErrNoPicture = errors.New("no embedded picture")
+
+// Picture is the cover art embedded into the media file.
+type Picture struct {
+	MIME string
+	Data []byte
+}
+
+type pictureReader func(r io.ReaderAt, size int64) (Picture, error)
+
+var pictureReaders = map[string]pictureReader{
+	".mp3":  readID3Picture,
+	".flac": readFLACPicture,
+	".mp4":  readMP4Picture,
+	".m4a":  readMP4Picture,
+	".m4b":  readMP4Picture,
+}
+
+// ReadPicture reads the embedded cover art of the file with the extension, the front cover is preferred.
+func ReadPicture(r io.ReaderAt, size int64, ext string) (Picture, error) {
+	var read, ok = pictureReaders[strings.ToLower(ext)]
+	if !ok {
+		return Picture{}, ErrUnsupported
+	}
+
+	var pic, err = read(r, size)
+	if err != nil {
+		return pic, err
+	}
+
+	if len(pic.Data) == 0 {
+		return pic, ErrNoPicture
+	}
+
+	// Declared types are often wrong.
+	pic.MIME = http.DetectContentType(pic.Data)
+
+	return pic, nil
+}
+
+func readID3Picture(r io.ReaderAt, size int64) (Picture, error) {
+	var pic Picture
+
+	var err = id3v2Frames(r, maxPictureSize, func(id string, data []byte) bool {
+		if id != "APIC" && id != "PIC" {
+			return true
+		}
+
+		var typ, image, ok = parseAPIC(id, data)
+		if !ok {
+			return true
+		}
+
+		if pic.Data == nil || typ == pictureFrontCover {
+			pic.Data = image
+		}
+
+		return typ != pictureFrontCover
+	})
+
+	return pic, err
+}
+
+// parseAPIC parses picture frame: encoding, MIME type (image format of ID3v2.2),
+// picture type, description and picture data.
+func parseAPIC(id string, data []byte) (byte, []byte, bool) {
+	if len(data) < 1 {
+		return 0, nil, false
+	}
+
+	var enc = data[0]
+	data = data[1:]
+
+	if id == "PIC" {
+		if len(data) < 3 {
+			return 0, nil, false
+		}
+		data = data[3:]
+	} else {
+		var i = bytes.IndexByte(data, 0)
+		if i < 0 {
+			return 0, nil, false
+		}
+		data = data[i+1:]
+	}
+
+	if len(data) < 1 {
+		return 0, nil, false
+	}
+
+	var typ = data[0]
+	data = data[1:]
+
+	// Description is terminated by null of the text encoding.
+	var i = -1
+	if enc == 1 || enc == 2 {
+		for j := 0; j+1 < len(data); j += 2 {
+			if data[j] == 0 && data[j+1] == 0 {
+				i = j + 2
+				break
+			}
+		}
+	} else if j := bytes.IndexByte(data, 0); j >= 0 {
+		i = j + 1
+	}
+
+	if i < 0 {
+		return 0, nil, false
+	}
+
+	return typ, data[i:], true
+}
+
+func readFLACPicture(r io.ReaderAt, size int64) (Picture, error) {
+	var start, err = id3v2Size(r)
+	if err != nil {
+		return Picture{}, err
+	}
+
+	var pic Picture
+
+	err = flacBlocks(r, start, func(typ byte, off int64, length int) (bool, error) {
+		if typ != flacPicture || length > maxPictureSize {
+			return true, nil
+		}
+
+		var b, err = readAt(r, off, length)
+		if err != nil {
+			return false, err
+		}
+
+		picType, image, ok := parseFLACPicture(b)
+		if !ok {
+			return true, nil
+		}
+
+		if pic.Data == nil || picType == pictureFrontCover {
+			pic.Data = image
+		}
+
+		return picType != pictureFrontCover, nil
+	})
+
+	return pic, err
+}
+
+// parseFLACPicture parses PICTURE block: picture type, MIME type, description,
+// width, height, color depth, number of colors and picture data.
+func parseFLACPicture(b []byte) (uint32, []byte, bool) {
+	var next = func(n int) ([]byte, bool) {
+		if n < 0 || len(b) < n {
+			return nil, false
+		}
+		var s = b[:n]
+		b = b[n:]
+		return s, true
+	}
+
+	var field = func() ([]byte, bool) {
+		var n, ok = next(4)
+		if !ok {
+			return nil, false
+		}
+		return next(int(be.Uint32(n)))
+	}
+
+	var typ, ok = next(4)
+	if !ok {
+		return 0, nil, false
+	}
+
+	// MIME type and description.
+	if _, ok = field(); !ok {
+		return 0, nil, false
+	}
+	if _, ok = field(); !ok {
+		return 0, nil, false
+	}
+
+	// Width, height, color depth and number of colors.
+	if _, ok = next(16); !ok {
+		return 0, nil, false
+	}
+
+	data, ok := field()
+	if !ok {
+		return 0, nil, false
+	}
+
+	return be.Uint32(typ), data, true
+}
+
+func readMP4Picture(r io.ReaderAt, size int64) (Picture, error) {
+	var data, ok, err = mp4Find(r, 0, size, "moov", "udta", "meta", "ilst", "covr", "data")
+	if err != nil || !ok || data.size <= 8 || data.size > maxPictureSize {
+		return Picture{}, err
+	}
+
+	b, err := readAt(r, data.off, int(data.size))
+	if err != nil {
+		return Picture{}, err
+	}
+
+	// Skip type indicator and locale.
+	return Picture{Data: b[8:]}, nil
+}
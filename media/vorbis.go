@@ -0,0 +1,164 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+const flacVorbisComment = 4
+
+// Vorbis comment fields.
+var vorbisFields = map[string]string{
+	"TITLE":       "title",
+	"ARTIST":      "artist",
+	"ALBUM":       "album",
+	"TRACKNUMBER": "track",
+	"DISCNUMBER":  "disc",
+}
+
+// parseVorbisComment parses comment header without framing:
+// vendor string followed by the list of "KEY=value" strings.
+func parseVorbisComment(b []byte, info *Info) error {
+	var next = func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+
+		var n = int(le.Uint32(b))
+		if n < 0 || len(b) < 4+n {
+			return nil, false
+		}
+
+		var s = b[4 : 4+n]
+		b = b[4+n:]
+
+		return s, true
+	}
+
+	// Vendor.
+	if _, ok := next(); !ok {
+		return ErrMalformed
+	}
+
+	if len(b) < 4 {
+		return ErrMalformed
+	}
+
+	var count = int(le.Uint32(b))
+	b = b[4:]
+
+	for i := 0; i < count; i++ {
+		var c, ok = next()
+		if !ok {
+			return ErrMalformed
+		}
+
+		var key, value, found = strings.Cut(string(c), "=")
+		if !found {
+			continue
+		}
+
+		if field, ok := vorbisFields[strings.ToUpper(key)]; ok {
+			info.setTag(field, strings.TrimSpace(value))
+		}
+	}
+
+	return nil
+}
+
+func readFLACTags(r io.ReaderAt, size int64, info *Info) error {
+	var start, err = id3v2Size(r)
+	if err != nil {
+		return err
+	}
+
+	return flacBlocks(r, start, func(typ byte, off int64, length int) (bool, error) {
+		switch typ {
+		case flacPicture:
+			info.Picture = true
+
+		case flacVorbisComment:
+			// Tags usually precede large values like pictures, the rest is cut off.
+			var truncated = length > maxTagSize
+			if truncated {
+				length = maxTagSize
+			}
+
+			var b, err = readAt(r, off, length)
+			if err != nil {
+				return false, err
+			}
+
+			err = parseVorbisComment(b, info)
+			if err != nil && !truncated {
+				return false, err
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// readOggTags reads comment header from the second packet of the stream.
+func readOggTags(r io.ReaderAt, size int64, info *Info) error {
+	const window = 64 << 10
+
+	var n = window
+	if size < int64(n) {
+		n = int(size)
+	}
+
+	var head, err = readAt(r, 0, n)
+	if err != nil {
+		return err
+	}
+
+	var packet []byte
+	var packets int
+
+	for off := 0; off < len(head); {
+		var page, ok = parseOggPage(head[off:], int64(off))
+		if !ok {
+			return ErrMalformed
+		}
+
+		var segments = head[off+26]
+		var lacing = head[off+27 : off+27+int(segments)]
+		var pos = int(page.off)
+
+		for _, l := range lacing {
+			if pos+int(l) > len(head) {
+				return ErrMalformed
+			}
+
+			if packets == 1 {
+				packet = append(packet, head[pos:pos+int(l)]...)
+			}
+			pos += int(l)
+
+			// Segment shorter than 255 bytes ends the packet.
+			if l < 255 {
+				packets++
+				if packets == 2 {
+					return parseOggComment(packet, info)
+				}
+			}
+		}
+
+		off = pos
+	}
+
+	return ErrMalformed
+}
+
+func parseOggComment(packet []byte, info *Info) error {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		return parseVorbisComment(packet[7:], info)
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		return parseVorbisComment(packet[8:], info)
+	default:
+		return ErrUnsupported
+	}
+}
@@ -0,0 +1,98 @@
+package playlist
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Names of cover art files by preference.
+var coverNames = []string{"cover", "folder", "front"}
+
+// Cover returns the cover art file of the track or the directory by its path
+// and the track to read embedded cover art from when there is no cover file.
+func Cover(files []*torrent.File, p string) (cover, track *torrent.File) {
+	var dir = strings.Trim(p, "/")
+
+	for _, f := range files {
+		if f.Path() == p {
+			dir = path.Dir(p)
+			track = f
+			break
+		}
+	}
+
+	if track == nil {
+		// First track of the directory.
+		for _, f := range files {
+			if strings.HasPrefix(f.Path(), dir+"/") && IsAudio(mime.TypeByExtension(path.Ext(f.Path()))) {
+				track = f
+				break
+			}
+		}
+	} else if !IsAudio(mime.TypeByExtension(path.Ext(track.Path()))) {
+		track = nil
+	}
+
+	cover, _ = findCover(coverFiles(files), dir)
+
+	return cover, track
+}
+
+// coverFiles returns the preferred cover art file of every directory.
+func coverFiles(files []*torrent.File) map[string]*torrent.File {
+	var covers = map[string]*torrent.File{}
+	var ranks = map[string]int{}
+
+	for _, f := range files {
+		var rank = coverRank(path.Base(f.Path()))
+		if rank < 0 {
+			continue
+		}
+
+		var dir = path.Dir(f.Path())
+
+		if r, ok := ranks[dir]; !ok || rank < r {
+			covers[dir] = f
+			ranks[dir] = rank
+		}
+	}
+
+	return covers
+}
+
+// findCover returns the cover art file of the directory or its parent directory.
+func findCover(covers map[string]*torrent.File, dir string) (*torrent.File, bool) {
+	if f, ok := covers[dir]; ok {
+		return f, true
+	}
+
+	// Multi-disc albums keep the cover in the album directory.
+	if parent := path.Dir(dir); parent != dir {
+		if f, ok := covers[parent]; ok {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+// coverRank returns the preference of the cover art file name, -1 means not a cover.
+func coverRank(base string) int {
+	var ext = path.Ext(base)
+	if !IsImage(mime.TypeByExtension(ext)) {
+		return -1
+	}
+
+	var name = strings.ToLower(strings.TrimSuffix(base, ext))
+
+	for i, n := range coverNames {
+		if name == n {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,178 @@
+package playlist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2"
+	"github.com/anacrolix/torrent"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/cue"
+)
+
+const (
+	// Cue sheets are small text files.
+	maxCueSize     = 1 << 20
+	cueReadTimeout = 10 * time.Second
+)
+
+type cueTracks struct {
+	sheet  *cue.Sheet
+	tracks []cue.Track
+	// Torrent file of the cue sheet.
+	file *torrent.File
+}
+
+// expandCueSheets replaces audio items described by cue sheets with their tracks
+// and drops used cue sheets from the content.
+func expandCueSheets(ctx context.Context, content []Item, files []*torrent.File) []Item {
+	var dirs = map[string]struct{}{}
+
+	for _, itm := range content {
+		if itm.file != nil && IsAudio(itm.MIME) {
+			dirs[path.Dir(itm.file.Path())] = struct{}{}
+		}
+	}
+
+	if len(dirs) == 0 {
+		return content
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cueReadTimeout)
+	defer cancel()
+
+	// Audio files by lower cased path with and without extension.
+	var sheets = map[string]cueTracks{}
+
+	for _, f := range files {
+		var dir = path.Dir(f.Path())
+
+		if _, ok := dirs[dir]; !ok || !cue.IsCue(path.Ext(f.Path())) {
+			continue
+		}
+
+		var sheet, err = readCueSheet(ctx, f)
+		if err != nil {
+			log.Warn().Err(err).Msgf("read cue sheet %s", f.Path())
+			continue
+		}
+
+		for _, cf := range sheet.Files {
+			var target = strings.ToLower(path.Join(dir, cf.Name))
+			var tracks = cueTracks{sheet: sheet, tracks: cf.Tracks, file: f}
+
+			sheets[target] = tracks
+
+			// Sheets often refer to the original .wav file.
+			var stem = strings.TrimSuffix(target, path.Ext(target))
+			if _, ok := sheets[stem]; !ok {
+				sheets[stem] = tracks
+			}
+		}
+	}
+
+	if len(sheets) == 0 {
+		return content
+	}
+
+	var expanded = make([]Item, 0, len(content))
+	var used = map[*torrent.File]struct{}{}
+
+	for _, itm := range content {
+		if itm.file == nil || !IsAudio(itm.MIME) {
+			expanded = append(expanded, itm)
+			continue
+		}
+
+		var target = strings.ToLower(itm.file.Path())
+
+		var ct, ok = sheets[target]
+		if !ok {
+			ct, ok = sheets[strings.TrimSuffix(target, filepath.Ext(target))]
+		}
+
+		if !ok || len(ct.tracks) == 0 {
+			expanded = append(expanded, itm)
+			continue
+		}
+
+		for _, t := range ct.tracks {
+			expanded = append(expanded, cueItem(itm, ct.sheet, t))
+		}
+
+		used[ct.file] = struct{}{}
+	}
+
+	// Drop used cue sheets.
+	var n int
+	for _, itm := range expanded {
+		if _, ok := used[itm.file]; ok && itm.file != nil {
+			continue
+		}
+		expanded[n] = itm
+		n++
+	}
+
+	return expanded[:n]
+}
+
+func readCueSheet(ctx context.Context, f *torrent.File) (*cue.Sheet, error) {
+	if f.Length() > maxCueSize {
+		return nil, fmt.Errorf("cue sheet is too large: %d bytes", f.Length())
+	}
+
+	var reader = f.NewReader()
+	defer reader.Close()
+
+	var src, err = io.ReadAll(missinggo.ContextedReader{R: reader, Ctx: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	return cue.Parse(src)
+}
+
+// cueItem makes the virtual track item of the audio file.
+func cueItem(itm Item, sheet *cue.Sheet, t cue.Track) Item {
+	var track = itm
+
+	track.cue = true
+	track.Start = t.Start
+	track.End = t.End
+	track.Track = t.Number
+
+	if t.Title != "" {
+		track.Name = t.Title
+		track.Title = t.Title
+	} else {
+		track.Name = fmt.Sprintf("%s %02d", itm.Name, t.Number)
+		track.Title = ""
+	}
+
+	if t.Performer != "" {
+		track.Artist = t.Performer
+	} else if sheet.Performer != "" {
+		track.Artist = sheet.Performer
+	}
+
+	if sheet.Title != "" {
+		track.Album = sheet.Title
+	}
+
+	switch {
+	case t.End > 0:
+		track.Duration = t.End - t.Start
+	case itm.Duration > t.Start:
+		track.Duration = itm.Duration - t.Start
+	default:
+		track.Duration = 0
+	}
+
+	return track
+}
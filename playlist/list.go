@@ -1,86 +1,441 @@
 package playlist
 
 import (
+	"context"
 	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
-
-	name_reader "github.com/WinPooh32/peerstohttp/playlist/name"
+	"time"
 
 	"github.com/anacrolix/torrent"
+	"github.com/rs/zerolog/log"
+
+	"github.com/WinPooh32/peerstohttp/archive"
+	name_reader "github.com/WinPooh32/peerstohttp/playlist/name"
 )
 
+// Size name of item thumbnails.
+const thumbnailSize = "medium"
+
+// Limits reading of zip directories, entries of archives left after the timeout are not listed.
+const archiveReadTimeout = 30 * time.Second
+
 type Header struct {
+	// Hash is empty for merged lists.
+	Hash  string `json:"hash"`
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	// Torrents of the merged list.
+	Sources []Source `json:"sources,omitempty"`
+	// Count of listed items before paging.
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	// Cursor of the next page, empty on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// Source is the torrent of listed items.
+type Source struct {
 	Hash  string `json:"hash"`
 	Name  string `json:"name"`
 	Files int    `json:"files"`
 }
 
+func newSource(t *torrent.Torrent) Source {
+	return Source{
+		Hash:  t.InfoHash().String(),
+		Name:  t.Name(),
+		Files: len(t.Files()),
+	}
+}
+
 type Item struct {
+	// Info hash of the source torrent.
+	Source   string   `json:"source"`
 	Name     string   `json:"name"`
 	NameOrig string   `json:"name_orig"`
 	Ext      string   `json:"ext"`
 	MIME     string   `json:"mime"`
 	Size     int64    `json:"size"`
 	Path     []string `json:"path"`
+	URL      string   `json:"url"`
+	// Duration in seconds, zero when unknown.
+	Duration float64 `json:"duration,omitempty"`
+	// Embedded tags of audio items.
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Track  int    `json:"track,omitempty"`
+	Disc   int    `json:"disc,omitempty"`
+	// Offsets of cue sheet tracks inside of the file in seconds, zero end means the end of the file.
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+	// Player page URL of audio and video items.
+	Player string `json:"player,omitempty"`
+	// Thumbnail URL of image items.
+	Thumbnail string `json:"thumbnail,omitempty"`
+	// Cover art URL of audio items.
+	Cover string `json:"cover,omitempty"`
+	// HLS playlist URL of MP4 video items.
+	HLS string `json:"hls,omitempty"`
 
 	Tags []string `json:"tags"`
+
+	Subtitles []Subtitle `json:"subtitles,omitempty"`
+
+	// Torrent of the item.
+	src Source
+	// Torrent file of the item, nil for archive entries.
+	file *torrent.File
+	// Item has embedded cover art.
+	picture bool
+	// Item is a track of cue sheet.
+	cue bool
 }
 
 type PlayList struct {
 	Header  Header `json:"header"`
 	Content []Item `json:"content"`
 
-	Torr       *torrent.Torrent    `json:"-"`
+	// Scheme, host and path prefix of item URLs.
+	Origin string `json:"-"`
+
+	Torr *torrent.Torrent `json:"-"`
+	// Torrents merged into the list following Torr.
+	Merge []*torrent.Torrent `json:"-"`
+	// Drops items of the same file name and size as the previous ones.
+	Dedup bool `json:"-"`
+
 	Whitelist  map[string]struct{} `json:"-"`
 	IgnoreTags map[string]struct{} `json:"-"`
+	// List entries of zip archives.
+	Archives bool `json:"-"`
+
+	// Sorting and paging of items.
+	Order Order `json:"-"`
+
+	// Reads media info of items when set.
+	Probe Prober `json:"-"`
+	// Limits reading of durations and tags, durations are not read when it's zero.
+	ProbeTimeout time.Duration `json:"-"`
+	// Limits reading of tags only, tags are not read when both timeouts are zero.
+	TagsTimeout time.Duration `json:"-"`
 }
 
 func (p *PlayList) Render(w http.ResponseWriter, r *http.Request) error {
-	var files = p.Torr.Files()
-	var name = p.Torr.Name()
+	var torrents = append([]*torrent.Torrent{p.Torr}, p.Merge...)
+	var content []Item
+
+	p.Header = Header{}
+
+	for _, t := range torrents {
+		var src = newSource(t)
+
+		content = append(content, p.torrentContent(r.Context(), t, src)...)
+
+		p.Header.Files += src.Files
+		p.Header.Sources = append(p.Header.Sources, src)
+	}
+
+	if len(p.Header.Sources) == 1 {
+		p.Header.Hash = p.Header.Sources[0].Hash
+		p.Header.Name = p.Header.Sources[0].Name
+		p.Header.Sources = nil
+	} else {
+		var names = make([]string, 0, len(p.Header.Sources))
+		for _, src := range p.Header.Sources {
+			names = append(names, src.Name)
+		}
+		p.Header.Name = strings.Join(names, " + ")
+	}
+
+	if p.Dedup {
+		content = dedup(content)
+	}
+
+	p.Header.Total = len(content)
+	p.Header.Offset = p.Order.Offset
+
+	// Title tags replace names, so names are resolved before sorting by them.
+	// Otherwise only the page is probed.
+	var sortByName = p.Order.Sort == SortName
+	if sortByName {
+		p.probe(r.Context(), content)
+	}
+
+	content = p.Order.apply(content)
+
+	if p.Order.Limit > 0 && p.Order.Offset+len(content) < p.Header.Total {
+		p.Header.Next = EncodeCursor(p.Order.Offset + len(content))
+	}
 
+	if !sortByName {
+		p.probe(r.Context(), content)
+	}
+
+	var origin = p.Origin
+	var covers = map[string]map[string]*torrent.File{}
+
+	for _, t := range torrents {
+		var hash = t.InfoHash().String()
+		if _, ok := covers[hash]; !ok {
+			covers[hash] = coverFiles(t.Files())
+		}
+	}
+
+	for i := range content {
+		var itm = &content[i]
+		var itemPath = contentPath(itm.src, itm.Path)
+
+		itm.URL = origin + "/content/" + itemPath
+
+		if IsAudio(itm.MIME) || IsVideo(itm.MIME) {
+			itm.Player = origin + "/player/" + itemPath + p.FilterQuery()
+		}
+
+		if IsVideo(itm.MIME) && itm.file != nil && isMP4(itm.Ext) {
+			itm.HLS = origin + "/hls/" + itemPath + "/index.m3u8"
+		}
+
+		// Archive entries are not supported by thumbnails.
+		if HasThumbnail(itm.MIME) && !isArchiveEntry(itm.Path) {
+			itm.Thumbnail = origin + "/thumbnail/" + thumbnailSize + "/" + itemPath
+		}
+
+		if IsAudio(itm.MIME) && itm.file != nil {
+			var _, ok = findCover(covers[itm.Source], path.Dir(itm.file.Path()))
+			if ok || itm.picture {
+				itm.Cover = origin + "/cover/" + itemPath
+			}
+		}
+
+		for j := range itm.Subtitles {
+			itm.Subtitles[j].URL = origin + "/subtitles/" + contentPath(itm.src, itm.Subtitles[j].Path)
+		}
+	}
+
+	p.Content = content
+
+	return nil
+}
+
+// torrentContent returns filtered items of the torrent.
+func (p *PlayList) torrentContent(ctx context.Context, t *torrent.Torrent, src Source) []Item {
+	var files = t.Files()
 	var content = make([]Item, 0, len(files))
 
+	// Archive readers are not responsive, they wait for pieces until the context is done.
+	var arcCtx, cancel = context.WithTimeout(ctx, archiveReadTimeout)
+	defer cancel()
+
 	for _, f := range files {
-		var path = f.FileInfo().Path
-		var base string
+		var path = filePath(f)
+		var base = path[len(path)-1]
 
 		var tags = ExtractPathTags(path)
 		if Overlap(tags, p.IgnoreTags) {
 			continue
 		}
 
-		if size := len(path); size > 1 {
-			base = path[len(path)-1]
-		} else if size == 1 {
-			base = path[0]
-		} else {
-			base = f.DisplayPath()
-			path = append(path, base)
+		var ext = filepath.Ext(base)
+
+		if p.Archives && archive.IsArchive(base) {
+			content = p.appendArchive(arcCtx, content, f, path)
+		}
+
+		if !p.whitelisted(ext) {
+			continue
+		}
+
+		var itm = makeItem(f.Length(), path, tags, base, ext)
+		itm.file = f
+
+		content = append(content, itm)
+	}
+
+	content = attachSubtitles(content, findSubtitles(files))
+
+	content = expandCueSheets(ctx, content, files)
+
+	for i := range content {
+		content[i].Source = src.Hash
+		content[i].src = src
+	}
+
+	return content
+}
+
+// dedup drops items of the same file name and size as the previous ones, cue sheet tracks are told apart by offsets.
+func dedup(content []Item) []Item {
+	type key struct {
+		name  string
+		size  int64
+		start float64
+	}
+
+	var seen = make(map[key]struct{}, len(content))
+	var unique = content[:0]
+
+	for _, itm := range content {
+		var k = key{itm.NameOrig, itm.Size, itm.Start}
+
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+
+		unique = append(unique, itm)
+	}
+
+	return unique
+}
+
+// ContentPath returns the escaped file path relative to the content routes, the list must have a single source.
+func (p *PlayList) ContentPath(path []string) string {
+	return contentPath(p.source(), path)
+}
+
+// ItemPath returns the file path following the hash in content routes.
+func (p *PlayList) ItemPath(path []string) string {
+	var name, rest = splitPath(p.source(), path)
+
+	if rest == "" {
+		return name
+	}
+
+	return name + "/" + rest
+}
+
+func (p *PlayList) source() Source {
+	return Source{Hash: p.Header.Hash, Name: p.Header.Name, Files: p.Header.Files}
+}
+
+func contentPath(src Source, path []string) string {
+	var name, rest = splitPath(src, path)
+
+	var contentURL = src.Hash + "/" + url.PathEscape(name)
+	if rest != "" {
+		contentURL += "/" + url.PathEscape(rest)
+	}
+
+	return contentURL
+}
+
+func splitPath(src Source, path []string) (name, rest string) {
+	name = src.Name
+
+	if src.Files <= 1 {
+		// Single file torrent has no directory, only archive entries are nested.
+		if len(path) > 1 {
+			rest = strings.Join(path[1:], "/")
+		}
+	} else {
+		rest = strings.Join(path, "/")
+	}
+
+	return name, rest
+}
+
+// FilterQuery returns the playlist filters as query string of the player route.
+func (p *PlayList) FilterQuery() string {
+	var q = url.Values{}
+
+	if len(p.Whitelist) != 0 {
+		var exts = make([]string, 0, len(p.Whitelist))
+		for ext := range p.Whitelist {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+		sort.Strings(exts)
+		q.Set("ext", strings.Join(exts, ","))
+	}
+
+	if len(p.IgnoreTags) != 0 {
+		var tags = make([]string, 0, len(p.IgnoreTags))
+		for tag := range p.IgnoreTags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		q.Set("exclude_tags", strings.Join(tags, ","))
+	}
+
+	if len(q) == 0 {
+		return ""
+	}
+
+	return "?" + q.Encode()
+}
+
+// filePath returns the file path inside of the torrent including the file name.
+func filePath(f *torrent.File) []string {
+	var path = f.FileInfo().Path
+
+	if len(path) == 0 {
+		path = []string{f.DisplayPath()}
+	}
+
+	return path
+}
+
+// appendArchive appends entries of the zip file to the content.
+// Entry path is the archive path followed by the separator and the path inside of the archive.
+func (p *PlayList) appendArchive(ctx context.Context, content []Item, f *torrent.File, path []string) []Item {
+	var arc, err = archive.Open(ctx, f.NewReader(), f.Length())
+	if err != nil {
+		log.Warn().Err(err).Msgf("open archive %s", f.Path())
+		return content
+	}
+	defer arc.Close()
+
+	for _, entry := range arc.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		var entryPath = make([]string, 0, len(path)+1)
+		entryPath = append(entryPath, path...)
+		entryPath = append(entryPath, archive.Separator)
+		entryPath = append(entryPath, strings.Split(entry.Name, "/")...)
+
+		var tags = ExtractPathTags(entryPath)
+		if Overlap(tags, p.IgnoreTags) {
+			continue
 		}
 
+		var base = entryPath[len(entryPath)-1]
 		var ext = filepath.Ext(base)
 
-		if len(p.Whitelist) != 0 {
-			if _, ok := p.Whitelist[ext]; !ok {
-				continue
-			}
+		if !p.whitelisted(ext) {
+			continue
 		}
 
-		content = append(content, makeItem(f, path, tags, base, ext))
+		content = append(content, makeItem(int64(entry.UncompressedSize64), entryPath, tags, base, ext))
 	}
 
-	p.Header.Name = name
-	p.Header.Hash = p.Torr.InfoHash().String()
-	p.Header.Files = len(p.Torr.Files())
-	p.Content = content
+	return content
+}
 
-	return nil
+func isArchiveEntry(path []string) bool {
+	for _, s := range path {
+		if s == archive.Separator {
+			return true
+		}
+	}
+	return false
 }
 
-func makeItem(file *torrent.File, path, tags []string, base, ext string) Item {
+func (p *PlayList) whitelisted(ext string) bool {
+	if len(p.Whitelist) == 0 {
+		return true
+	}
+	var _, ok = p.Whitelist[ext]
+	return ok
+}
+
+func makeItem(size int64, path, tags []string, base, ext string) Item {
 	var mime = mime.TypeByExtension(ext)
 	var name = strings.TrimSuffix(base, ext)
 
@@ -91,7 +446,7 @@ func makeItem(file *torrent.File, path, tags []string, base, ext string) Item {
 		case "video":
 			// TODO
 		case "image":
-			// TODO
+			// Thumbnail URL is set on render.
 		}
 	}
 
@@ -100,7 +455,7 @@ func makeItem(file *torrent.File, path, tags []string, base, ext string) Item {
 		NameOrig: base,
 		Ext:      ext,
 		MIME:     mime,
-		Size:     file.Length(),
+		Size:     size,
 		Path:     path,
 		Tags:     tags,
 	}
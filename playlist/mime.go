@@ -0,0 +1,89 @@
+package playlist
+
+import (
+	"mime"
+	"strings"
+)
+
+// Media types missing on minimal systems without mime.types file (e.g. alpine docker image).
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".ape":  "audio/x-ape",
+	".wv":   "audio/x-wavpack",
+	".aac":  "audio/aac",
+	".wma":  "audio/x-ms-wma",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".wmv":  "video/x-ms-wmv",
+	".ts":   "video/mp2t",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".srt":  "application/x-subrip",
+	".ass":  "text/x-ssa",
+	".ssa":  "text/x-ssa",
+	".vtt":  "text/vtt",
+	".cue":  "application/x-cue",
+}
+
+// MP4 containers packaged to HLS.
+var mp4Exts = map[string]struct{}{
+	".mp4": {},
+	".m4v": {},
+	".mov": {},
+}
+
+// Image types decoded by thumbnails.
+var thumbnailTypes = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+}
+
+func isMP4(ext string) bool {
+	var _, ok = mp4Exts[strings.ToLower(ext)]
+	return ok
+}
+
+func init() {
+	for ext, typ := range mediaTypes {
+		if mime.TypeByExtension(ext) == "" {
+			_ = mime.AddExtensionType(ext, typ)
+		}
+	}
+}
+
+// IsVideo reports whether the media type is video.
+func IsVideo(mime string) bool {
+	return strings.HasPrefix(mime, "video/")
+}
+
+// IsAudio reports whether the media type is audio.
+func IsAudio(mime string) bool {
+	return strings.HasPrefix(mime, "audio/")
+}
+
+// IsImage reports whether the media type is image.
+func IsImage(mime string) bool {
+	return strings.HasPrefix(mime, "image/")
+}
+
+// HasThumbnail reports whether thumbnails are made of the media type.
+func HasThumbnail(mime string) bool {
+	var _, ok = thumbnailTypes[mime]
+	return ok
+}
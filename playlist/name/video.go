@@ -0,0 +1,90 @@
+package name
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Video string
+
+// VideoInfo is parsed from video file names like "Show.Name.S01E02.720p.mkv" or "Movie (2010) BDRip".
+type VideoInfo struct {
+	Title   string
+	Year    int
+	Season  int
+	Episode int
+}
+
+var (
+	videoEpisode = regexp.MustCompile(`(?i)\bs(\d{1,2})[ ._-]?e(\d{1,3})\b|\b(\d{1,2})x(\d{2,3})\b`)
+	videoYear    = regexp.MustCompile(`[(\[ ]((?:19|20)\d{2})[)\] ]|[(\[ ]((?:19|20)\d{2})$`)
+	// Release tags following the title.
+	videoTags = regexp.MustCompile(`(?i)[(\[ ](?:\d{3,4}p|[248]k|bdrip|brrip|bluray|blu-ray|web-?dl|web-?rip|webrip|hdtv|hdrip|dvdrip|dvd|x26[45]|h\.?26[45]|hevc|xvid|divx|remux|proper|repack|extended|unrated)(?:[)\] .-]|$)`)
+)
+
+func (v Video) Name() string {
+	return v.Parse().Title
+}
+
+// Parse takes title, year, season and episode of the name without extension.
+func (v Video) Parse() VideoInfo {
+	var s = string(v)
+
+	// Dots and underscores are word separators of scene names.
+	if !strings.Contains(s, " ") {
+		s = strings.NewReplacer(".", " ", "_", " ").Replace(s)
+	}
+
+	// Release group prefix like "[Group] Title".
+	if strings.HasPrefix(s, "[") {
+		if i := strings.IndexByte(s, ']'); i > 0 && i+1 < len(s) {
+			s = strings.TrimSpace(s[i+1:])
+		}
+	}
+
+	var info VideoInfo
+	var end = len(s)
+
+	if m := videoEpisode.FindStringSubmatchIndex(s); m != nil {
+		var season, episode = submatch(s, m, 1), submatch(s, m, 2)
+		if season == "" {
+			season, episode = submatch(s, m, 3), submatch(s, m, 4)
+		}
+
+		info.Season, _ = strconv.Atoi(season)
+		info.Episode, _ = strconv.Atoi(episode)
+		end = m[0]
+	}
+
+	// Year at the beginning is a part of the title like "1917".
+	if m := videoYear.FindStringSubmatchIndex(s); m != nil && m[0] > 0 {
+		var year = submatch(s, m, 1)
+		if year == "" {
+			year = submatch(s, m, 2)
+		}
+
+		info.Year, _ = strconv.Atoi(year)
+		if m[0] < end {
+			end = m[0]
+		}
+	}
+
+	if m := videoTags.FindStringIndex(s); m != nil && m[0] > 0 && m[0] < end {
+		end = m[0]
+	}
+
+	info.Title = strings.Trim(s[:end], " -([.")
+	if info.Title == "" {
+		info.Title = strings.TrimSpace(s)
+	}
+
+	return info
+}
+
+func submatch(s string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+	return s[m[2*i]:m[2*i+1]]
+}
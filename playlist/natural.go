@@ -0,0 +1,64 @@
+package playlist
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NaturalLess compares strings case insensitively treating digit runs as numbers,
+// so "track 2" goes before "track 10".
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		var ra, sa = utf8.DecodeRuneInString(a)
+		var rb, sb = utf8.DecodeRuneInString(b)
+
+		if isDigit(ra) && isDigit(rb) {
+			var na, nb string
+			na, a = digits(a)
+			nb, b = digits(b)
+
+			if c := compareNumbers(na, nb); c != 0 {
+				return c < 0
+			}
+			continue
+		}
+
+		var la, lb = unicode.ToLower(ra), unicode.ToLower(rb)
+		if la != lb {
+			return la < lb
+		}
+
+		a = a[sa:]
+		b = b[sb:]
+	}
+
+	return len(a) < len(b)
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func digits(s string) (string, string) {
+	var i = 0
+	for i < len(s) && isDigit(rune(s[i])) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareNumbers compares decimal numbers of any length.
+func compareNumbers(a, b string) int {
+	var ta, tb = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+
+	switch {
+	case len(ta) != len(tb):
+		return len(ta) - len(tb)
+	case ta != tb:
+		return strings.Compare(ta, tb)
+	default:
+		// More leading zeros go first.
+		return len(b) - len(a)
+	}
+}
@@ -0,0 +1,52 @@
+package playlist
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		less bool
+	}{
+		{"track 2", "track 10", true},
+		{"track 10", "track 2", false},
+		{"Track 2", "track 10", true},
+		{"a", "B", true},
+		{"b", "A", false},
+		{"a", "a", false},
+		{"", "a", true},
+		{"a", "", false},
+		{"track", "track 1", true},
+		{"01", "1", true},
+		{"1", "01", false},
+		{"007", "7a", true},
+		{"9", "10", true},
+		{"99999999999999999999999", "100000000000000000000000", true},
+		{"100000000000000000000000", "99999999999999999999999", false},
+		{"1.9", "1.10", true},
+		{"x2y3", "x2y12", true},
+		{"2", "a", true},
+		{"Ärzte 2", "ärzte 10", true},
+		{"Б 10", "б 9", false},
+	}
+
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.less {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+}
+
+func TestNaturalSort(t *testing.T) {
+	var names = []string{"CD2/10 - Ten.flac", "CD10/1.flac", "cd2/2 - Two.flac", "CD1/1.flac", "CD2/1.flac"}
+	var want = []string{"CD1/1.flac", "CD2/1.flac", "cd2/2 - Two.flac", "CD2/10 - Ten.flac", "CD10/1.flac"}
+
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("sorted = %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,124 @@
+package playlist
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort keys of playlist items.
+const (
+	SortPath = "path"
+	SortName = "name"
+	SortSize = "size"
+	SortExt  = "ext"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Order sorts and pages playlist items, zero value keeps all items in the torrent order.
+type Order struct {
+	Sort   string
+	Desc   bool
+	Offset int
+	// Zero means no limit.
+	Limit int
+}
+
+// IsSortKey reports whether the key is supported by Order.
+func IsSortKey(key string) bool {
+	switch key {
+	case SortPath, SortName, SortSize, SortExt:
+		return true
+	default:
+		return false
+	}
+}
+
+// EncodeCursor returns the cursor of the page starting from the offset.
+// Torrent content is immutable, so offset in the sorted list is stable.
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// DecodeCursor returns the offset of the cursor.
+func DecodeCursor(cursor string) (int, error) {
+	var b, err = base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	if !strings.HasPrefix(string(b), "offset:") {
+		return 0, ErrInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(b), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return offset, nil
+}
+
+// apply sorts the content and returns the page of it.
+func (o Order) apply(content []Item) []Item {
+	if o.Sort != "" {
+		var less = itemLess(o.Sort)
+
+		sort.SliceStable(content, func(i, j int) bool {
+			if o.Desc {
+				return less(&content[j], &content[i])
+			}
+			return less(&content[i], &content[j])
+		})
+	}
+
+	if o.Offset >= len(content) {
+		return content[:0]
+	}
+	content = content[o.Offset:]
+
+	if o.Limit > 0 && o.Limit < len(content) {
+		content = content[:o.Limit]
+	}
+
+	return content
+}
+
+func itemLess(key string) func(a, b *Item) bool {
+	var byPath = func(a, b *Item) bool {
+		return NaturalLess(strings.Join(a.Path, "/"), strings.Join(b.Path, "/"))
+	}
+
+	switch key {
+	case SortName:
+		return func(a, b *Item) bool {
+			if a.Name != b.Name {
+				return NaturalLess(a.Name, b.Name)
+			}
+			return byPath(a, b)
+		}
+
+	case SortSize:
+		return func(a, b *Item) bool {
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+			return byPath(a, b)
+		}
+
+	case SortExt:
+		return func(a, b *Item) bool {
+			var ea, eb = strings.ToLower(a.Ext), strings.ToLower(b.Ext)
+			if ea != eb {
+				return ea < eb
+			}
+			return byPath(a, b)
+		}
+
+	default:
+		return byPath
+	}
+}
@@ -0,0 +1,99 @@
+package playlist
+
+import (
+	"context"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/WinPooh32/peerstohttp/media"
+)
+
+const probeWorkers = 8
+
+// Prober reads media info of torrent files.
+type Prober interface {
+	// MediaInfo reads durations and embedded tags.
+	MediaInfo(ctx context.Context, file *torrent.File) (media.Info, error)
+	// MediaTags reads only embedded tags.
+	MediaTags(ctx context.Context, file *torrent.File) (media.Info, error)
+}
+
+// probe fills media info of audio and video items, items left after the timeout stay untouched.
+// Durations are read when ProbeTimeout is set, only tags are read within TagsTimeout otherwise.
+func (p *PlayList) probe(ctx context.Context, content []Item) {
+	if p.Probe == nil {
+		return
+	}
+
+	var read, timeout = p.Probe.MediaInfo, p.ProbeTimeout
+	if timeout <= 0 {
+		read, timeout = p.Probe.MediaTags, p.TagsTimeout
+	}
+	if timeout <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var jobs = make(chan *Item)
+
+	for i := 0; i < probeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for itm := range jobs {
+				var info, err = read(ctx, itm.file)
+				if err != nil {
+					continue
+				}
+				itm.setInfo(info)
+			}
+		}()
+	}
+
+loop:
+	for i := range content {
+		var itm = &content[i]
+
+		if itm.file == nil || !(IsAudio(itm.MIME) || IsVideo(itm.MIME)) || !media.Supported(itm.Ext) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case jobs <- itm:
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// setInfo fills the item from media info, the name parsed from the file name stays when there is no title tag.
+func (itm *Item) setInfo(info media.Info) {
+	itm.picture = info.Picture
+
+	// Cue sheet tracks keep own tags, the last track lasts until the end of the file.
+	if itm.cue {
+		if itm.End == 0 && info.Duration > itm.Start {
+			itm.Duration = info.Duration - itm.Start
+		}
+		return
+	}
+
+	itm.Duration = info.Duration
+	itm.Artist = info.Artist
+	itm.Album = info.Album
+	itm.Track = info.Track
+	itm.Disc = info.Disc
+
+	if info.Title != "" {
+		itm.Title = info.Title
+		itm.Name = info.Title
+	}
+}
@@ -0,0 +1,115 @@
+package playlist
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+
+	"github.com/WinPooh32/peerstohttp/subtitle"
+)
+
+// Subtitle is a subtitles file attached to the video item.
+type Subtitle struct {
+	Name string   `json:"name"`
+	Lang string   `json:"lang,omitempty"`
+	Ext  string   `json:"ext"`
+	Path []string `json:"path"`
+	URL  string   `json:"url"`
+}
+
+type subtitleFile struct {
+	// Lower cased file name without extension.
+	stem string
+	sub  Subtitle
+}
+
+func findSubtitles(files []*torrent.File) []subtitleFile {
+	var subs []subtitleFile
+
+	for _, f := range files {
+		var path = filePath(f)
+		var base = path[len(path)-1]
+		var ext = filepath.Ext(base)
+
+		if !subtitle.IsSubtitle(ext) {
+			continue
+		}
+
+		subs = append(subs, subtitleFile{
+			stem: strings.ToLower(strings.TrimSuffix(base, ext)),
+			sub: Subtitle{
+				Name: base,
+				Ext:  ext,
+				Path: path,
+			},
+		})
+	}
+
+	return subs
+}
+
+// matchSubtitles returns subtitles named after the video like "video.srt" or "video.en.srt".
+// The only video of the torrent takes all subtitles.
+func matchSubtitles(subs []subtitleFile, video string, only bool) []Subtitle {
+	var stem = strings.ToLower(video)
+	var matched []Subtitle
+
+	for _, s := range subs {
+		var sub = s.sub
+
+		switch {
+		case s.stem == stem:
+		case strings.HasPrefix(s.stem, stem+"."):
+			sub.Lang = s.stem[len(stem)+1:]
+		case only:
+			if i := strings.LastIndexByte(s.stem, '.'); i >= 0 && len(s.stem)-i <= 4 {
+				sub.Lang = s.stem[i+1:]
+			}
+		default:
+			continue
+		}
+
+		matched = append(matched, sub)
+	}
+
+	return matched
+}
+
+// attachSubtitles attaches subtitles to the video items and drops attached subtitles from the content.
+func attachSubtitles(content []Item, subs []subtitleFile) []Item {
+	if len(subs) == 0 {
+		return content
+	}
+
+	var videos int
+	for _, itm := range content {
+		if IsVideo(itm.MIME) {
+			videos++
+		}
+	}
+
+	var attached = map[string]struct{}{}
+
+	for i, itm := range content {
+		if !IsVideo(itm.MIME) {
+			continue
+		}
+
+		content[i].Subtitles = matchSubtitles(subs, strings.TrimSuffix(itm.NameOrig, itm.Ext), videos == 1)
+
+		for _, sub := range content[i].Subtitles {
+			attached[strings.Join(sub.Path, "/")] = struct{}{}
+		}
+	}
+
+	var filtered = content[:0]
+	for _, itm := range content {
+		if _, ok := attached[strings.Join(itm.Path, "/")]; ok {
+			continue
+		}
+		filtered = append(filtered, itm)
+	}
+
+	return filtered
+}
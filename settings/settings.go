@@ -5,6 +5,9 @@ import "flag"
 type Settings struct {
 	Host            *string
 	Port            *int
+	Templates       *string
+	BaseURL         *string
+	TrustedProxies  *string
 	TorrPort        *int
 	ProxyHTTP       *string
 	DownloadDir     *string
@@ -12,6 +15,13 @@ type Settings struct {
 	UploadRate      *int
 	MaxConnections  *int
 	CacheCapacity   *int64
+	ThumbsCapacity  *int64
+	Verified        *bool
+	VerifiedTimeout *int
+	ProbeTimeout    *int
+	TagsTimeout     *int
+	TranscodeConfig *string
+	TranscodeIdle   *int
 	NoDHT           *bool
 	NoUPnP          *bool
 	NoTCP           *bool
@@ -27,8 +37,11 @@ type Settings struct {
 func (s *Settings) parse() {
 	*s = Settings{
 		// HTTP
-		Host: flag.String("host", "0.0.0.0", "listening server ip"),
-		Port: flag.Int("port", 80, "listening port"),
+		Host:           flag.String("host", "0.0.0.0", "listening server ip"),
+		Port:           flag.Int("port", 80, "listening port"),
+		Templates:      flag.String("templates", "", "directory of html templates overriding the embedded ones"),
+		BaseURL:        flag.String("base-url", "", "external url of the service like https://example.com/torrents\nor path prefix like /torrents to mount routes under"),
+		TrustedProxies: flag.String("trusted-proxies", "", "comma separated ips or networks of proxies trusted to set\nForwarded, X-Forwarded-Proto and X-Forwarded-Host headers"),
 
 		// Torrent
 		TorrPort:        flag.Int("port-torr", 0, "listening port for torrent"),
@@ -45,6 +58,17 @@ func (s *Settings) parse() {
 		NoIPv6:          flag.Bool("no-ipv6", false, "disable IPv6"),
 		ForceEncryption: flag.Bool("force-encryption", false, "force encryption"),
 		CacheCapacity:   flag.Int64("cache-capacity", 10240, "files cache capacity in MiB\nvalue less then or equal 0 disables cache size controlling"),
+		ThumbsCapacity:  flag.Int64("thumbnails-capacity", 64, "thumbnails cache capacity in MiB, it's a part of the cache capacity\nvalue less then or equal 0 disables caching of thumbnails"),
+
+		// Streaming
+		Verified:        flag.Bool("verified", false, "serve only hash checked data by default"),
+		VerifiedTimeout: flag.Int("verified-timeout", 60, "seconds to wait for a piece to be verified before aborting the transfer"),
+		ProbeTimeout:    flag.Int("probe-timeout", 0, "seconds to read media durations while listing\nvalue less then or equal 0 disables probing"),
+		TagsTimeout:     flag.Int("tags-timeout", 5, "seconds to read embedded tags while listing when durations are not probed\nvalue less then or equal 0 disables reading of tags"),
+
+		// Transcoding
+		TranscodeConfig: flag.String("transcode-config", "", "path to json config of transcoding profiles"),
+		TranscodeIdle:   flag.Int("transcode-idle", 30, "seconds to wait for transcoder output before killing it, must be positive"),
 
 		// Debug
 		JsonLogs:     flag.Bool("json-logs", false, "json logs output"),
@@ -56,6 +80,7 @@ func (s *Settings) parse() {
 
 	// Convert MiB to bytes.
 	*s.CacheCapacity = *s.CacheCapacity << 20
+	*s.ThumbsCapacity = *s.ThumbsCapacity << 20
 }
 
 var Service *Settings
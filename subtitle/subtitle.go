@@ -0,0 +1,192 @@
+package subtitle
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/WinPooh32/peerstohttp/charset"
+)
+
+var ErrUnsupported = errors.New("unsupported subtitle format")
+
+var exts = map[string]struct{}{
+	".srt": {},
+	".ass": {},
+	".ssa": {},
+	".vtt": {},
+}
+
+// IsSubtitle reports whether the file extension is a supported subtitle format.
+func IsSubtitle(ext string) bool {
+	var _, ok = exts[strings.ToLower(ext)]
+	return ok
+}
+
+// ToWebVTT converts subtitles of the format given by the file extension to WebVTT.
+// Source text encoding is detected automatically.
+func ToWebVTT(src []byte, ext string) (string, error) {
+	var text = normalize(charset.Decode(src))
+
+	switch strings.ToLower(ext) {
+	case ".vtt":
+		return text, nil
+	case ".srt":
+		return fromSRT(text), nil
+	case ".ass", ".ssa":
+		return fromASS(text), nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+func normalize(s string) string {
+	s = strings.TrimPrefix(s, "\uFEFF")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return s
+}
+
+var srtTiming = regexp.MustCompile(`(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})\s*-->\s*(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})`)
+
+func fromSRT(s string) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+
+	for _, block := range strings.Split(s, "\n\n") {
+		var lines = strings.Split(strings.Trim(block, "\n"), "\n")
+
+		// Skip cue number.
+		for len(lines) > 0 && !srtTiming.MatchString(lines[0]) {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		var m = srtTiming.FindStringSubmatch(lines[0])
+
+		sb.WriteString("\n")
+		sb.WriteString(timestamp(m[1], m[2], m[3], m[4]))
+		sb.WriteString(" --> ")
+		sb.WriteString(timestamp(m[5], m[6], m[7], m[8]))
+		sb.WriteString("\n")
+
+		for _, line := range lines[1:] {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+var (
+	assTime = regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})[.:](\d{1,3})$`)
+	assTags = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+func fromASS(s string) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+
+	var events bool
+	var format []string
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			events = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !events {
+			continue
+		}
+
+		var key, value, ok = strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "Format":
+			format = strings.Split(value, ",")
+			for i := range format {
+				format[i] = strings.ToLower(strings.TrimSpace(format[i]))
+			}
+
+		case "Dialogue":
+			if len(format) == 0 {
+				continue
+			}
+
+			// Text is the last field and may contain commas.
+			var fields = strings.SplitN(value, ",", len(format))
+			if len(fields) != len(format) {
+				continue
+			}
+
+			var start, end, text string
+			for i, name := range format {
+				switch name {
+				case "start":
+					start = assTimestamp(fields[i])
+				case "end":
+					end = assTimestamp(fields[i])
+				case "text":
+					text = assText(fields[i])
+				}
+			}
+
+			if start == "" || end == "" || text == "" {
+				continue
+			}
+
+			sb.WriteString("\n" + start + " --> " + end + "\n" + text + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+func assTimestamp(s string) string {
+	var m = assTime.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return ""
+	}
+
+	return timestamp(m[1], m[2], m[3], m[4])
+}
+
+// Characters starting tags and entities of WebVTT cue text.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func assText(s string) string {
+	s = assTags.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, `\N`, "\n")
+	s = strings.ReplaceAll(s, `\n`, "\n")
+	s = strings.ReplaceAll(s, `\h`, " ")
+
+	// Blank line ends WebVTT cue.
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return vttEscaper.Replace(strings.Join(lines, "\n"))
+}
+
+// timestamp formats WebVTT time, fraction of second is padded to milliseconds.
+func timestamp(h, m, s, frac string) string {
+	return pad(h, 2) + ":" + pad(m, 2) + ":" + pad(s, 2) + "." + (frac + "00")[:3]
+}
+
+func pad(s string, n int) string {
+	for len(s) < n {
+		s = "0" + s
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package subtitle
+
+import (
+	"errors"
+	"testing"
+)
+
+const assHeader = "[Script Info]\nTitle: Test\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"
+
+func TestToWebVTT(t *testing.T) {
+	var tests = []struct {
+		name string
+		src  string
+		ext  string
+		want string
+		err  error
+	}{
+		{
+			name: "srt with cue numbers",
+			src:  "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n00:00:03,000 --> 00:00:04,000\nTwo\nlines\n",
+			ext:  ".srt",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nHello\n\n00:00:03.000 --> 00:00:04.000\nTwo\nlines\n",
+		},
+		{
+			name: "srt short fraction digits",
+			src:  "1\n0:0:1,5 --> 0:0:2,25\nHi\n",
+			ext:  ".SRT",
+			want: "WEBVTT\n\n00:00:01.500 --> 00:00:02.250\nHi\n",
+		},
+		{
+			name: "srt crlf and bom",
+			src:  "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nBye\r\n",
+			ext:  ".srt",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n\n00:00:03.000 --> 00:00:04.000\nBye\n",
+		},
+		{
+			name: "ass commas in text",
+			src:  assHeader + "Dialogue: 0,0:00:01.50,0:00:02.00,Default,,0,0,0,,One, two, three\n",
+			ext:  ".ass",
+			want: "WEBVTT\n\n00:00:01.500 --> 00:00:02.000\nOne, two, three\n",
+		},
+		{
+			name: "ass line breaks",
+			src:  assHeader + "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,One\\Ntwo\\N\\Nthree\\nfour\\hfive\n",
+			ext:  ".ass",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nOne\ntwo\nthree\nfour five\n",
+		},
+		{
+			name: "ass override tags",
+			src:  assHeader + "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\an8}{\\i1}Top{\\i0}\n",
+			ext:  ".ssa",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nTop\n",
+		},
+		{
+			name: "ass escaped text",
+			src:  assHeader + "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Tom & Jerry <3 -->\n",
+			ext:  ".ass",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nTom &amp; Jerry &lt;3 --&gt;\n",
+		},
+		{
+			name: "ass empty and malformed dialogues",
+			src: assHeader +
+				"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\pos(1,1)}\n" +
+				"Dialogue: 0,xx,0:00:02.00,Default,,0,0,0,,Bad time\n" +
+				"Dialogue: 0,0:00:01.00\n",
+			ext:  ".ass",
+			want: "WEBVTT\n",
+		},
+		{
+			name: "vtt",
+			src:  "WEBVTT\r\n\r\n00:01.000 --> 00:02.000\r\n<i>Hi</i>\r\n",
+			ext:  ".vtt",
+			want: "WEBVTT\n\n00:01.000 --> 00:02.000\n<i>Hi</i>\n",
+		},
+		{
+			name: "unsupported",
+			src:  "text",
+			ext:  ".sub",
+			err:  ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = ToWebVTT([]byte(tt.src), tt.ext)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ToWebVTT() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
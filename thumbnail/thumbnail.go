@@ -0,0 +1,132 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register supported source formats.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Fixed thumbnail sizes by name, thumbnail fits the square of the size.
+var sizes = map[string]int{
+	"small":  160,
+	"medium": 320,
+	"large":  640,
+}
+
+const quality = 80
+
+// Limits pixel count of the source image, decoded images take 4 or 8 bytes per pixel.
+const maxPixels = 32 << 20
+
+var (
+	ErrUnknownSize = errors.New("unknown thumbnail size")
+	ErrTooLarge    = errors.New("image is too large")
+)
+
+// Size returns the thumbnail size in pixels by its name.
+func Size(name string) (int, error) {
+	var size, ok = sizes[name]
+	if !ok {
+		return 0, ErrUnknownSize
+	}
+	return size, nil
+}
+
+// Make decodes JPEG, PNG or GIF image and encodes its JPEG thumbnail fitting the size.
+// Images of more than maxPixels pixels are rejected before decoding.
+func Make(r io.Reader, size int) ([]byte, error) {
+	// Header read by DecodeConfig is replayed to Decode.
+	var head bytes.Buffer
+
+	var cfg, _, err = image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = jpeg.Encode(&buf, resize(src, size), &jpeg.Options{Quality: quality})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resize scales the image down to fit the size averaging source pixels of every destination pixel.
+func resize(src image.Image, size int) image.Image {
+	var b = src.Bounds()
+	var sw, sh = b.Dx(), b.Dy()
+
+	var dw, dh = sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, sh*size/sw
+		} else {
+			dw, dh = sw*size/sh, size
+		}
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	var dst = image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		var y0 = b.Min.Y + y*sh/dh
+		var y1 = b.Min.Y + (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dw; x++ {
+			var x0 = b.Min.X + x*sw/dw
+			var x1 = b.Min.X + (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// Colors are alpha-premultiplied, blend them over white background.
+					var cr, cg, cb, ca = src.At(sx, sy).RGBA()
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,127 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	var tests = []struct {
+		name string
+		size int
+		err  error
+	}{
+		{"small", 160, nil},
+		{"medium", 320, nil},
+		{"large", 640, nil},
+		{"huge", 0, ErrUnknownSize},
+		{"", 0, ErrUnknownSize},
+	}
+
+	for _, tt := range tests {
+		var size, err = Size(tt.name)
+		if size != tt.size || !errors.Is(err, tt.err) {
+			t.Errorf("Size(%q) = %d, %v, want %d, %v", tt.name, size, err, tt.size, tt.err)
+		}
+	}
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	var img = image.NewRGBA(image.Rect(0, 0, w, h))
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMake(t *testing.T) {
+	var tests = []struct {
+		name   string
+		src    []byte
+		size   int
+		width  int
+		height int
+		err    error
+	}{
+		{name: "landscape", src: encodePNG(t, 400, 200), size: 160, width: 160, height: 80},
+		{name: "portrait", src: encodePNG(t, 300, 600), size: 320, width: 160, height: 320},
+		{name: "thin", src: encodePNG(t, 1000, 2), size: 160, width: 160, height: 1},
+		{name: "small is not enlarged", src: encodePNG(t, 100, 50), size: 640, width: 100, height: 50},
+		// GIF header of 8193x4096 screen, pixel count is checked before decoding.
+		{name: "over 32 megapixels", src: []byte("GIF89a\x01\x20\x00\x10\x00\x00\x00;"), size: 160, err: ErrTooLarge},
+		{name: "huge gif", src: []byte("GIF89a\xff\x7f\xff\x7f\x00\x00\x00;"), size: 160, err: ErrTooLarge},
+		{name: "not an image", src: []byte("text"), size: 160, err: image.ErrFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data, err = Make(bytes.NewReader(tt.src), tt.size)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	// Columns of black, white, transparent and half transparent red pixels.
+	var src = image.NewNRGBA(image.Rect(0, 0, 8, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 8; x++ {
+			var c = []color.NRGBA{
+				{A: 0xff},
+				{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+				{},
+				{R: 0xff, A: 0x80},
+			}[x/2]
+			src.SetNRGBA(x, y, c)
+		}
+	}
+
+	var dst = resize(src, 4).(*image.RGBA)
+
+	if dst.Bounds() != image.Rect(0, 0, 4, 1) {
+		t.Fatalf("bounds = %v, want 4x1", dst.Bounds())
+	}
+
+	var want = []color.RGBA{
+		{A: 0xff},
+		{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		// Transparent pixels are blended over white background.
+		{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		{R: 0xff, G: 0x7f, B: 0x7f, A: 0xff},
+	}
+
+	for x, c := range want {
+		if got := dst.RGBAAt(x, 0); got != c {
+			t.Errorf("pixel %d = %v, want %v", x, got, c)
+		}
+	}
+
+	// Destination pixel averages the box of source pixels.
+	var stripes = image.NewGray(image.Rect(0, 0, 2, 1))
+	stripes.Pix[1] = 0xff
+	if got := resize(stripes, 1).(*image.RGBA).RGBAAt(0, 0); got != (color.RGBA{R: 0x7f, G: 0x7f, B: 0x7f, A: 0xff}) {
+		t.Errorf("average of black and white = %v, want gray", got)
+	}
+}
@@ -0,0 +1,283 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Profile names matched by rules.
+const ProfileAuto = "auto"
+
+var ErrNoCommand = errors.New("profile has no command")
+
+// Profile is an external command reading the source file from stdin and writing the result to stdout.
+type Profile struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+	// Content type and file extension of the output.
+	MIME string `json:"mime"`
+	Ext  string `json:"ext"`
+	// Max count of concurrent processes, zero means no limit.
+	Concurrency int `json:"concurrency"`
+
+	slots chan struct{}
+}
+
+// Rule picks the profile for clients by user agent and source file extension.
+type Rule struct {
+	// Regular expression of the user agent, empty matches any.
+	UserAgent string `json:"user_agent"`
+	// Source file extensions like ".mkv", empty matches any.
+	Exts    []string `json:"exts"`
+	Profile string   `json:"profile"`
+
+	userAgent *regexp.Regexp
+}
+
+// Config is the transcoding config file.
+type Config struct {
+	Profiles []*Profile `json:"profiles"`
+	Rules    []*Rule    `json:"rules"`
+}
+
+// Transcoder runs the configured profiles.
+type Transcoder struct {
+	profiles map[string]*Profile
+	rules    []*Rule
+}
+
+// Load reads JSON config of transcoding profiles.
+func Load(path string) (*Transcoder, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var config Config
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	return New(config)
+}
+
+// New validates the config and makes transcoder of it.
+func New(config Config) (*Transcoder, error) {
+	var t = &Transcoder{
+		profiles: map[string]*Profile{},
+	}
+
+	for _, p := range config.Profiles {
+		switch {
+		case p.Name == "" || p.Name == ProfileAuto:
+			return nil, fmt.Errorf("invalid profile name %q", p.Name)
+		case len(p.Command) == 0:
+			return nil, fmt.Errorf("profile %s: %w", p.Name, ErrNoCommand)
+		case p.MIME == "":
+			return nil, fmt.Errorf("profile %s: no mime type", p.Name)
+		}
+
+		if p.Concurrency > 0 {
+			p.slots = make(chan struct{}, p.Concurrency)
+		}
+
+		t.profiles[p.Name] = p
+	}
+
+	for _, r := range config.Rules {
+		if _, ok := t.profiles[r.Profile]; !ok {
+			return nil, fmt.Errorf("rule refers to unknown profile %q", r.Profile)
+		}
+
+		if r.UserAgent != "" {
+			var err error
+
+			r.userAgent, err = regexp.Compile(r.UserAgent)
+			if err != nil {
+				return nil, fmt.Errorf("rule of profile %s: %w", r.Profile, err)
+			}
+		}
+
+		t.rules = append(t.rules, r)
+	}
+
+	return t, nil
+}
+
+// Profile returns the profile by name.
+func (t *Transcoder) Profile(name string) (*Profile, bool) {
+	var p, ok = t.profiles[name]
+	return p, ok
+}
+
+// Match returns the profile of the first rule matching the user agent and the source file extension.
+func (t *Transcoder) Match(userAgent, ext string) (*Profile, bool) {
+	for _, r := range t.rules {
+		if r.userAgent != nil && !r.userAgent.MatchString(userAgent) {
+			continue
+		}
+
+		if len(r.Exts) != 0 && !hasExt(r.Exts, ext) {
+			continue
+		}
+
+		return t.profiles[r.Profile], true
+	}
+
+	return nil, false
+}
+
+func hasExt(exts []string, ext string) bool {
+	for _, e := range exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run streams the input through the profile command to the output.
+// The process is killed when the context is done, the input fails or it produces no output
+// within the idle timeout counted since the first input byte.
+func (p *Profile) Run(ctx context.Context, in io.Reader, out io.Writer, idle time.Duration) error {
+	if p.slots != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case p.slots <- struct{}{}:
+		}
+		defer func() { <-p.slots }()
+	}
+
+	var ctx2, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	var stderr = &tailBuffer{max: 4 << 10}
+	var cmd = exec.CommandContext(ctx2, p.Command[0], p.Command[1:]...)
+	cmd.Stderr = stderr
+
+	var stdin, err = cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("start %s: %w", p.Command[0], err)
+	}
+
+	// Idle timer is started by the first input byte.
+	var timer = time.AfterFunc(idle, cancel)
+	timer.Stop()
+	defer timer.Stop()
+
+	var input = &inputReader{r: in, timer: timer, idle: idle}
+	var inputErr = make(chan error, 1)
+
+	go func() {
+		// Ends when the process exits and closes stdin.
+		_, _ = io.Copy(stdin, input)
+		_ = stdin.Close()
+
+		if input.err != nil {
+			inputErr <- input.err
+			cancel()
+		}
+	}()
+
+	_, copyErr := io.Copy(&idleWriter{w: out, timer: timer, idle: idle}, stdout)
+
+	err = cmd.Wait()
+
+	var readErr error
+	select {
+	case readErr = <-inputErr:
+	default:
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// Client has gone.
+		return nil
+	case readErr != nil:
+		return fmt.Errorf("profile %s: read input: %w", p.Name, readErr)
+	case ctx2.Err() != nil:
+		return fmt.Errorf("profile %s: no output within %s", p.Name, idle)
+	case copyErr != nil:
+		return copyErr
+	case err != nil:
+		return fmt.Errorf("profile %s: %w: %s", p.Name, err, stderr.String())
+	}
+
+	return nil
+}
+
+// inputReader starts the idle timer on the first read byte and keeps the read error.
+type inputReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	idle    time.Duration
+	started bool
+	err     error
+}
+
+func (i *inputReader) Read(b []byte) (int, error) {
+	var n, err = i.r.Read(b)
+
+	if n > 0 && !i.started {
+		i.started = true
+		i.timer.Reset(i.idle)
+	}
+
+	if err != nil && err != io.EOF {
+		i.err = err
+	}
+
+	return n, err
+}
+
+// idleWriter resets the idle timer on every write.
+type idleWriter struct {
+	w     io.Writer
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (i *idleWriter) Write(b []byte) (int, error) {
+	i.timer.Reset(i.idle)
+	return i.w.Write(b)
+}
+
+// tailBuffer keeps the last max bytes written.
+type tailBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.buf.Write(b)
+	if t.buf.Len() > t.max {
+		t.buf.Next(t.buf.Len() - t.max)
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	return strings.TrimSpace(t.buf.String())
+}